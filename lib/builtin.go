@@ -8,3 +8,4 @@ func println(args ...interface{})
 func make(t _type_, args ...int) interface{} // not really.
 func len(coll interface{}) int
 func panic(arg interface{})
+func recover() interface{}
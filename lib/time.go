@@ -0,0 +1,13 @@
+package time
+
+// Durations are ints, counting milliseconds.
+const (
+	Millisecond = 1
+	Second      = 1000 * Millisecond
+)
+
+// After returns a chan that receives true, after ms milliseconds.
+func After(ms int) chan bool
+
+// Sleep blocks this goroutine for ms milliseconds, while others run.
+func Sleep(ms int)
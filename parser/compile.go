@@ -8,7 +8,6 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -47,6 +46,7 @@ type ExprVisitor interface {
 	VisitLitString(*LitStringX) Value
 	VisitIdent(*IdentX) Value
 	VisitBinOp(*BinOpX) Value
+	VisitUnaryOp(*UnaryX) Value
 	VisitCall(*CallX) Value
 	VisitSub(*SubX) Value
 	VisitSubSlice(*SubSliceX) Value
@@ -54,6 +54,8 @@ type ExprVisitor interface {
 	VisitTypeAssert(*TypeAssertX) Value
 	VisitConstructor(*ConstructorX) Value
 	VisitFunction(*FunctionX) Value
+	VisitRecv(*RecvX) Value
+	VisitArrayType(*ArrayTX) Value
 }
 
 type Expr interface {
@@ -76,10 +78,17 @@ type PointerTX struct {
 type SliceTX struct {
 	E NameTX
 }
+type ArrayTX struct {
+	N Expr // length, a constant
+	E NameTX
+}
 type MapTX struct {
 	K NameTX
 	V NameTX
 }
+type ChanTX struct {
+	E NameTX
+}
 type StructTX struct {
 	StructRecX *StructRecX
 }
@@ -159,7 +168,9 @@ func (r *FuncRec) String() string {
 
 func (o *PointerTX) String() string { return Format("PointerTX(%v)", o.E) }
 func (o *SliceTX) String() string   { return Format("SliceTX(%v)", o.E) }
+func (o *ArrayTX) String() string   { return Format("ArrayTX(%v, %v)", o.N, o.E) }
 func (o *MapTX) String() string     { return Format("MapTX(%v=>%v)", o.K, o.V) }
+func (o *ChanTX) String() string    { return Format("ChanTX(%v)", o.E) }
 func (o *StructTX) String() string {
 	if o.StructRecX == nil {
 		return "StructTX[nil]"
@@ -194,6 +205,9 @@ func (o *SliceTX) VisitExpr(v ExprVisitor) Value {
 	Say(z)
 	return &TypeVal{z}
 }
+func (o *ArrayTX) VisitExpr(v ExprVisitor) Value {
+	return v.VisitArrayType(o)
+}
 func (o *MapTX) VisitExpr(v ExprVisitor) Value {
 	z := &MapTV{
 		K: CompileTX(v, o.K, o).TV,
@@ -201,6 +215,12 @@ func (o *MapTX) VisitExpr(v ExprVisitor) Value {
 	}
 	return &TypeVal{z}
 }
+func (o *ChanTX) VisitExpr(v ExprVisitor) Value {
+	z := &ChanTV{
+		E: CompileTX(v, o.E, o).TV,
+	}
+	return &TypeVal{z}
+}
 
 func (o *StructTX) VisitExpr(v ExprVisitor) Value {
 	p := o.StructRecX
@@ -285,6 +305,11 @@ type PointerTV struct {
 type SliceTV struct {
 	E TypeValue
 }
+type ArrayTV struct {
+	N     int
+	E     TypeValue
+	cname string // of the C typedef of a struct holding the array.
+}
 type DotDotDotSliceTV struct {
 	E TypeValue
 }
@@ -292,6 +317,9 @@ type MapTV struct {
 	K TypeValue
 	V TypeValue
 }
+type ChanTV struct {
+	E TypeValue
+}
 type StructTV struct {
 	StructRec *StructRec
 }
@@ -305,18 +333,66 @@ type MultiTV struct {
 	Multi []NameTV
 }
 
+// NamedTV is a defined type over a type that is not a struct,
+// like `type Celsius int` or `type Path []string`.
+// It has the C representation and operations of its underlying
+// type E, but its own identity and (value receiver) methods.
+// To be put in a non-empty interface, a value is boxed in a
+// heap object of its own class.
+type NamedTV struct {
+	name  string
+	cname string
+	E     TypeValue
+	Meths []NameTV
+}
+
+// Underlying is the type that a NamedTV is defined over,
+// or the type itself if it is not a NamedTV.
+func Underlying(tv TypeValue) TypeValue {
+	if nt, ok := tv.(*NamedTV); ok {
+		return nt.E
+	}
+	return tv
+}
+
 func (tv *PrimTV) TypeCode() string      { return tv.typecode }
 func (tv *TypeTV) TypeCode() string      { return "t" }
 func (tv *PointerTV) TypeCode() string   { return "P" + tv.E.TypeCode() }
 func (tv *SliceTV) TypeCode() string     { return "S" + tv.E.TypeCode() }
+func (tv *ArrayTV) TypeCode() string     { return F("A%d%s", tv.N, tv.E.TypeCode()) }
 func (tv *MapTV) TypeCode() string       { return "M" + tv.K.TypeCode() + tv.V.TypeCode() }
+func (tv *ChanTV) TypeCode() string      { return "C" + tv.E.TypeCode() }
 func (tv *StructTV) TypeCode() string    { return "R0" }
 func (tv *InterfaceTV) TypeCode() string { return "I0" }
 func (tv *MultiTV) TypeCode() string     { return "?" }
+func (tv *NamedTV) TypeCode() string     { return tv.E.TypeCode() }
 func (tv *FunctionTV) TypeCode() string {
 	return tv.FuncRec.BuildTypeCode(false)
 }
 
+// DynamicTypeCode is the typecode stored with a value of type tv
+// in an `interface {}`, for type switches and assertions.
+// TypeCode gives a defined type the code of its representation;
+// here it is followed by `@cname;`, so `Celsius` is not `int`.
+// The first byte is still that of the representation.
+func DynamicTypeCode(tv TypeValue) string {
+	switch t := tv.(type) {
+	case *NamedTV:
+		return DynamicTypeCode(t.E) + "@" + t.cname + ";"
+	case *PointerTV:
+		return "P" + DynamicTypeCode(t.E)
+	case *SliceTV:
+		return "S" + DynamicTypeCode(t.E)
+	case *ArrayTV:
+		return F("A%d%s", t.N, DynamicTypeCode(t.E))
+	case *MapTV:
+		return "M" + DynamicTypeCode(t.K) + DynamicTypeCode(t.V)
+	case *ChanTV:
+		return "C" + DynamicTypeCode(t.E)
+	}
+	return tv.TypeCode()
+}
+
 func (rec *FuncRec) BuildTypeCode(omitFirst bool) string {
 	var buf bytes.Buffer
 	if omitFirst {
@@ -346,13 +422,16 @@ func (tv *PrimTV) Type() TypeValue    { return &TypeTV{} }
 func (tv *TypeTV) Type() TypeValue    { return &TypeTV{} }
 func (tv *PointerTV) Type() TypeValue { return &TypeTV{} }
 func (tv *SliceTV) Type() TypeValue   { return &TypeTV{} }
+func (tv *ArrayTV) Type() TypeValue   { return &TypeTV{} }
 func (tv *MapTV) Type() TypeValue     { return &TypeTV{} }
+func (tv *ChanTV) Type() TypeValue    { return &TypeTV{} }
 
 func (tv *StructTV) Type() TypeValue    { return &TypeTV{} }
 func (tv *InterfaceTV) Type() TypeValue { return &TypeTV{} }
 func (tv *FunctionTV) Type() TypeValue  { return &TypeTV{} }
 
 func (tv *MultiTV) Type() TypeValue { return &TypeTV{} }
+func (tv *NamedTV) Type() TypeValue { return &TypeTV{} }
 
 func ToC(v Value) string {
 	if v == nil {
@@ -373,9 +452,15 @@ func (tv *PointerTV) ToC() string {
 func (tv *SliceTV) ToC() string {
 	return Format("ZSlice(%s)", tv.E)
 }
+func (tv *ArrayTV) ToC() string {
+	return Format("ZArray(%d, %s)", tv.N, tv.E)
+}
 func (tv *MapTV) ToC() string {
 	return Format("ZMap(%s, %s)", tv.K, tv.V)
 }
+func (tv *ChanTV) ToC() string {
+	return Format("ZChan(%s)", tv.E)
+}
 
 func (tv *StructTV) ToC() string {
 	return Format("ZStruct(%s)", tv.StructRec.cname)
@@ -390,6 +475,9 @@ func (tv *FunctionTV) ToC() string {
 func (tv *MultiTV) ToC() string {
 	return Format("ZMulti(...)")
 }
+func (tv *NamedTV) ToC() string {
+	return Format("ZNamed(%s)", tv.cname)
+}
 
 func (o *PrimTV) Intlike() bool {
 	switch o.name {
@@ -402,7 +490,9 @@ func (o *PrimTV) Intlike() bool {
 func (o *FunctionTV) Equals(typ TypeValue) bool {
 	switch t := typ.(type) {
 	case *FunctionTV:
-		return reflect.DeepEqual(o, t)
+		// Functions of the same signature are interchangeable,
+		// regardless of parameter names.
+		return o.TypeCode() == t.TypeCode()
 	}
 	return false
 }
@@ -427,6 +517,13 @@ func (o *SliceTV) Equals(typ TypeValue) bool {
 	}
 	return false
 }
+func (o *ArrayTV) Equals(typ TypeValue) bool {
+	switch t := typ.(type) {
+	case *ArrayTV:
+		return o.N == t.N && o.E.Equals(t.E)
+	}
+	return false
+}
 func (o *PointerTV) Equals(typ TypeValue) bool {
 	switch t := typ.(type) {
 	case *PointerTV:
@@ -441,6 +538,13 @@ func (o *MapTV) Equals(typ TypeValue) bool {
 	}
 	return false
 }
+func (o *ChanTV) Equals(typ TypeValue) bool {
+	switch t := typ.(type) {
+	case *ChanTV:
+		return o.E.Equals(t.E)
+	}
+	return false
+}
 func (o *StructTV) Equals(typ TypeValue) bool {
 	switch t := typ.(type) {
 	case *StructTV:
@@ -458,6 +562,13 @@ func (o *InterfaceTV) Equals(typ TypeValue) bool {
 func (o *MultiTV) Equals(typ TypeValue) bool {
 	panic("cannot compare MultiTV")
 }
+func (o *NamedTV) Equals(typ TypeValue) bool {
+	switch t := typ.(type) {
+	case *NamedTV:
+		return o.cname == t.cname
+	}
+	return false
+}
 
 func (o *PointerTV) TypeOfHandle() (z string, ok bool) {
 	if st, ok := o.E.(*StructTV); ok {
@@ -481,29 +592,61 @@ func (o *PrimTV) Zero() string {
 // Zero
 
 func (o *SliceTV) Zero() string     { return "{0, 0, 0}" }
+func (o *ArrayTV) Zero() string     { return "{0}" }
 func (o *MapTV) Zero() string       { return "(void*)0" }
+func (o *ChanTV) Zero() string      { return "0" }
 func (o *StructTV) Zero() string    { panic("Zero Struct") }
 func (o *PointerTV) Zero() string   { return "(void*)0" }
 func (o *InterfaceTV) Zero() string { return "(void*)0" }
 func (o *TypeTV) Zero() string      { panic("Zero Type") }
 func (o *MultiTV) Zero() string     { panic("Zero Multi") }
-func (o *FunctionTV) Zero() string  { return "(void*)0" }
+func (o *FunctionTV) Zero() string  { return "{0, 0}" }
+func (o *NamedTV) Zero() string     { return o.E.Zero() }
 
 // CType
 
 func (o *PrimTV) CType() string      { return "P_" + o.name }
 func (o *SliceTV) CType() string     { return F("Slice_(%s)", o.E.CType()) }
+func (o *ArrayTV) CType() string     { return o.cname }
 func (o *MapTV) CType() string       { return F("Map_(%s,%s)", o.K.CType(), o.V.CType()) }
+func (o *ChanTV) CType() string      { return F("Chan_(%s)", o.E.CType()) }
 func (o *StructTV) CType() string    { return F("struct %s", o.StructRec.cname) }
 func (o *PointerTV) CType() string   { return F("%s*", o.E.CType()) }
 func (o *InterfaceTV) CType() string { return F("Interface_(%s)", o.InterfaceRec.name) }
 func (o *TypeTV) CType() string      { return "Type" }
 func (o *MultiTV) CType() string     { return "Multi" }
 
-func (o *FunctionTV) CType() string { return o.FuncRec.TODO_PtrTypedef }
+func (o *FunctionTV) CType() string { return "Func" }
+func (o *NamedTV) CType() string    { return o.E.CType() }
 
 func (co *Compiler) CastToType(from Value, toType TypeValue) Value {
 	L("// CastToType: from %v to %v", from, toType)
+	if _, ok := ConstInt(from); !ok && Underlying(from.Type()).Equals(Underlying(toType)) {
+		// Between a named type and its underlying type,
+		// or another named type over it, the value is the same.
+		return &CVal{from.ToC(), toType}
+	}
+	if Underlying(toType) == StringTO {
+		switch from.Type().TypeCode() {
+		case "b", "i", "k":
+			// A byte or rune, encoded in UTF-8.
+			str := co.DefineLocalTempC(Serial("from_rune_to_string"), toType, "")
+			co.P("%s = FromRuneToString(%s);", str.ToC(), from.ToC())
+			return str
+		}
+	}
+	if sliceT, ok := Underlying(toType).(*SliceTV); ok && Underlying(from.Type()) == StringTO {
+		switch sliceT.E {
+		case ByteTO:
+			bytes := co.DefineLocalTempC(Serial("from_string_to_bytes"), toType, "")
+			co.P("%s = FromStringToBytes(%s);", bytes.ToC(), from.ToC())
+			return bytes
+		case IntTO:
+			runes := co.DefineLocalTempC(Serial("from_string_to_runes"), toType, "")
+			co.P("%s = FromStringToRunes(%s);", runes.ToC(), from.ToC())
+			return runes
+		}
+	}
 	// Quick and Dirty int casts
 	switch from.Type().TypeCode()[0] {
 	case 'b', 'i', 'u', 'k', 'p':
@@ -511,29 +654,35 @@ func (co *Compiler) CastToType(from Value, toType TypeValue) Value {
 		switch toType.TypeCode()[0] {
 		case 'b', 'i', 'u', 'k', 'p':
 			L("// CASE#Z")
-			z := co.DefineLocalTempC("cast", toType, "")
+			CheckConstFits(from, toType)
+			if k, ok := ConstInt(from); ok {
+				// Converting a constant gives a typed constant.
+				return TypedK(k, toType)
+			}
+			z := co.DefineLocalTempC(Serial("cast"), toType, "")
 			L("// CastToType: from %v to %v", from, toType)
 			co.P("%s = (%s)(%s); // L488 CastTo", z.CName, toType.CType(), from.ToC())
 			return z
 		}
 	case 'S':
 		L("// CASE#B")
-		sliceT, ok := from.Type().(*SliceTV)
+		sliceT, ok := Underlying(from.Type()).(*SliceTV)
 		assert(ok)
-		if sliceT.E == ByteTO && toType.TypeCode() == "s" {
+		if sliceT.E == ByteTO && Underlying(toType) == StringTO {
 			// Convert []byte to string
 			ser := Serial("from_bytes_to_string")
-			str := co.DefineLocalTempC(ser, StringTO, "")
+			str := co.DefineLocalTempC(ser, toType, "")
 			co.P("%s = FromBytesToString(%s);", str.ToC(), from.ToC())
 			return str
 		}
 	}
-	panic(F("cannot Cast (yet?): %v TO TYPE %v", from, toType))
+	panic(F("cannot convert %v (type %s) to type %s", from, TypeName(from.Type()), TypeName(toType)))
 }
 func (co *Compiler) ConvertTo(from Value, to Value) {
 	co.ConvertToCNameType(from, to.ToC(), to.Type())
 }
 func (co *Compiler) ConvertToCNameType(from Value, toCName string, toType TypeValue) {
+	from = co.FuncValue(from)
 	if from.Type().Equals(toType) {
 		// Same type, just assign.
 		co.P("%s = %s; // L451", toCName, from.ToC())
@@ -541,19 +690,28 @@ func (co *Compiler) ConvertToCNameType(from Value, toCName string, toType TypeVa
 	}
 
 	if from.Type() == ConstIntTO {
-		switch toType {
-		case ByteTO:
-			co.P("%s = (P_byte)(%s);", toCName, from.ToC())
+		CheckConstFits(from, toType)
+		switch Underlying(toType) {
+		case ByteTO, IntTO, UintTO, UintptrTO:
+			co.P("%s = (%s)(%s);", toCName, toType.CType(), from.ToC())
 			return
-		case IntTO:
-			co.P("%s = (P_int)(%s);", toCName, from.ToC())
-			return
-		case UintTO:
-			co.P("%s = (P_uint)(%s);", toCName, from.ToC())
+		}
+	}
+
+	// Named types are assignable from (and to) values of their
+	// underlying type, if that is unnamed, or an untyped string.
+	if _, ok := from.(*StringConstVal); ok || IsUnnamed(from.Type()) || IsUnnamed(toType) {
+		if Underlying(from.Type()).Equals(Underlying(toType)) {
+			co.P("%s = %s; // L600", toCName, from.ToC())
 			return
 		}
 	}
 
+	if _, ok := Underlying(toType).(*FunctionTV); ok && from.Type() == NilTO {
+		co.P("%s.fn = 0; %s.env = 0; // L603 [nil to func]", toCName, toCName)
+		return
+	}
+
 	// Case of assigning to interface{}.
 	if toType == AnyTO {
 		if from.Type() == ConstIntTO {
@@ -561,7 +719,16 @@ func (co *Compiler) ConvertToCNameType(from Value, toCName string, toType TypeVa
 			ser := Serial("constint")
 			from = co.DefineLocalTempC(ser, IntTO, from.ToC())
 		}
+		if from == TRUE || from == FALSE {
+			// Nor of P_true or P_false.
+			from = co.DefineLocalTempC(Serial("constbool"), BoolTO, from.ToC())
+		}
 
+		if from.Type() == NilTO {
+			co.P("%s.pointer = 0; // L594", toCName)
+			co.P("%s.typecode = 0; // L595", toCName)
+			return
+		}
 		if from.Type() == AnyTO {
 			// Skip the reify!
 			dest := toCName
@@ -573,7 +740,7 @@ func (co *Compiler) ConvertToCNameType(from Value, toCName string, toType TypeVa
 		rfrom := co.Reify(from)
 		dest := toCName
 		co.P("%s.pointer = &%s; // L458", dest, rfrom.ToC())
-		co.P("%s.typecode = %q; // L459", dest, rfrom.Type().TypeCode())
+		co.P("%s.typecode = %q; // L459", dest, DynamicTypeCode(rfrom.Type()))
 		return
 	}
 
@@ -589,15 +756,49 @@ func (co *Compiler) ConvertToCNameType(from Value, toCName string, toType TypeVa
 			co.P("%s = (void*)0; // L507 [nil to face]", toCName)
 			return
 		}
+		if ft, ok2 := from.Type().(*InterfaceTV); ok2 {
+			if m := MissingMethod(ft.InterfaceRec.Meths, false, toType.(*InterfaceTV).InterfaceRec); m != "" {
+				panic(F("cannot use %s as %s: missing method %s",
+					GoTypeName(from.Type()), GoTypeName(toType), m))
+			}
+			co.P("%s = %s; // L512 [face to face]", toCName, from.ToC())
+			return
+		}
+		if nt, ok2 := from.Type().(*NamedTV); ok2 {
+			// Box the value in a heap object of its class.
+			rfrom := co.Reify(from)
+			co.P("%s = (%s)oalloc(sizeof(%s), CLASS_%s); // L517 [box to face]",
+				toCName, toType.CType(), nt.CType(), nt.cname)
+			co.P("*(%s*)(%s) = %s;", nt.CType(), toCName, rfrom.ToC())
+			return
+		}
 	}
 	panic(F("Cannot assign: (%v :: %v) = %v", toCName, toType, from))
 }
 
+// IsNamedTV tells if tv is a defined type over a non-struct type.
+func IsNamedTV(tv TypeValue) bool {
+	_, ok := tv.(*NamedTV)
+	return ok
+}
+
+// IsUnnamed tells if tv is a type literal, like []int or func(),
+// rather than a predeclared or defined type.
+func IsUnnamed(tv TypeValue) bool {
+	switch tv.(type) {
+	case *PointerTV, *SliceTV, *MapTV, *ChanTV, *FunctionTV:
+		return true
+	}
+	return false
+}
+
 func (tv *PrimTV) String() string    { return Format("PrimTV(%q)", tv.name) }
 func (tv *TypeTV) String() string    { return Format("TypeTV(%q)", tv.name) }
 func (tv *PointerTV) String() string { return Format("PointerTV(%v)", tv.E) }
 func (tv *SliceTV) String() string   { return Format("SliceTV(%v)", tv.E) }
+func (tv *ArrayTV) String() string   { return Format("ArrayTV(%d, %v)", tv.N, tv.E) }
 func (tv *MapTV) String() string     { return Format("MapTV(%v=>%v)", tv.K, tv.V) }
+func (tv *ChanTV) String() string    { return Format("ChanTV(%v)", tv.E) }
 
 func (tv *StructTV) String() string {
 	return Format("StructTV(%v)", tv.StructRec.cname)
@@ -612,6 +813,7 @@ func (tv *InterfaceTV) String() string {
 func (tv *FunctionTV) String() string { return Format("FunctionTV(%v)", tv.FuncRec) }
 
 func (tv *MultiTV) String() string { return Format("MultiTV(%v)", tv.Multi) }
+func (tv *NamedTV) String() string { return Format("NamedTV(%s=%v)", tv.cname, tv.E) }
 
 type LitIntX struct {
 	X int
@@ -660,6 +862,19 @@ func (o *BinOpX) VisitExpr(v ExprVisitor) Value {
 	return v.VisitBinOp(o)
 }
 
+// UnaryX is `!X` or `^X`.  (Unary `-X` is parsed as `0 - X`.)
+type UnaryX struct {
+	Op string
+	X  Expr
+}
+
+func (o *UnaryX) String() string {
+	return fmt.Sprintf("Unary(%q %v)", o.Op, o.X)
+}
+func (o *UnaryX) VisitExpr(v ExprVisitor) Value {
+	return v.VisitUnaryOp(o)
+}
+
 type NameAndExpr struct {
 	name string
 	expr Expr
@@ -750,6 +965,17 @@ func (o *SubSliceX) VisitExpr(v ExprVisitor) Value {
 	return v.VisitSubSlice(o)
 }
 
+type RecvX struct {
+	Chan Expr
+}
+
+func (o *RecvX) String() string {
+	return fmt.Sprintf("Recv(%s)", o.Chan)
+}
+func (o *RecvX) VisitExpr(v ExprVisitor) Value {
+	return v.VisitRecv(o)
+}
+
 /////////// Stmt
 
 type StmtVisitor interface {
@@ -758,11 +984,17 @@ type StmtVisitor interface {
 	VisitWhile(*WhileS)
 	VisitFor(*ForS)
 	VisitSwitch(*SwitchS)
+	VisitTypeSwitch(*TypeSwitchS)
+	VisitSelect(*SelectS)
 	VisitIf(*IfS)
 	VisitReturn(*ReturnS)
 	VisitBlock(*Block)
 	VisitBreak(*BreakS)
 	VisitContinue(*ContinueS)
+	VisitFallthrough(*FallthroughS)
+	VisitDefer(*DeferS)
+	VisitGo(*GoS)
+	VisitSend(*SendS)
 }
 
 type Stmt interface {
@@ -801,12 +1033,24 @@ func (o *VarStmt) VisitStmt(v StmtVisitor) {
 type ReturnS struct {
 	X []Expr
 }
+type DeferS struct {
+	Call *CallX
+}
+type GoS struct {
+	Call *CallX
+}
+type SendS struct {
+	Chan  Expr
+	Value Expr
+}
 type BreakS struct {
 	Label string
 }
 type ContinueS struct {
 	Label string
 }
+type FallthroughS struct {
+}
 
 func (o *ReturnS) String() string {
 	return fmt.Sprintf("\nReturn(%v)\n", o.X)
@@ -816,6 +1060,30 @@ func (o *ReturnS) VisitStmt(v StmtVisitor) {
 	v.VisitReturn(o)
 }
 
+func (o *DeferS) String() string {
+	return fmt.Sprintf("\nDefer(%v)\n", o.Call)
+}
+
+func (o *DeferS) VisitStmt(v StmtVisitor) {
+	v.VisitDefer(o)
+}
+
+func (o *GoS) String() string {
+	return fmt.Sprintf("\nGo(%v)\n", o.Call)
+}
+
+func (o *GoS) VisitStmt(v StmtVisitor) {
+	v.VisitGo(o)
+}
+
+func (o *SendS) String() string {
+	return fmt.Sprintf("\nSend(%v <- %v)\n", o.Chan, o.Value)
+}
+
+func (o *SendS) VisitStmt(v StmtVisitor) {
+	v.VisitSend(o)
+}
+
 func (o *BreakS) String() string {
 	return fmt.Sprintf("\nBreak(%v)\n", o.Label)
 }
@@ -832,24 +1100,71 @@ func (o *ContinueS) VisitStmt(v StmtVisitor) {
 	v.VisitContinue(o)
 }
 
+func (o *FallthroughS) String() string {
+	return "\nFallthrough()\n"
+}
+
+func (o *FallthroughS) VisitStmt(v StmtVisitor) {
+	v.VisitFallthrough(o)
+}
+
 type Case struct {
-	Matches []Expr
+	Matches []Expr // nil for `default`, in a SwitchS.
 	Body    *Block
 }
+
+// SwitchS is `switch Init; Switch {...}`, where Init and Switch
+// may be nil.  The default is among the Cases, in its place,
+// in case the case before it falls through.
 type SwitchS struct {
-	Switch  Expr
-	Cases   []*Case
-	Default *Block
+	Init   Stmt
+	Switch Expr
+	Cases  []*Case
 }
 
 func (o *SwitchS) String() string {
-	return fmt.Sprintf("\nSwitch(switch: %v, cases: [[[ %#v ]]], default: %v )\n", o.Switch, o.Cases, o.Default)
+	return fmt.Sprintf("\nSwitch(init: %v, switch: %v, cases: [[[ %#v ]]] )\n", o.Init, o.Switch, o.Cases)
 }
 
 func (o *SwitchS) VisitStmt(v StmtVisitor) {
 	v.VisitSwitch(o)
 }
 
+// TypeSwitchS is `switch Var := X.(type) {...}`,
+// with the Matches in its Cases being types (or nil).
+type TypeSwitchS struct {
+	Init    Stmt   // nil if none.
+	Var     string // empty if no variable is bound.
+	X       Expr
+	Cases   []*Case
+	Default *Block
+}
+
+func (o *TypeSwitchS) String() string {
+	return fmt.Sprintf("\nTypeSwitch(%s := %v, cases: [[[ %#v ]]], default: %v )\n", o.Var, o.X, o.Cases, o.Default)
+}
+
+func (o *TypeSwitchS) VisitStmt(v StmtVisitor) {
+	v.VisitTypeSwitch(o)
+}
+
+type SelectCase struct {
+	Comm Stmt // *SendS, or *AssignS with a RecvX on the right.
+	Body *Block
+}
+type SelectS struct {
+	Cases   []*SelectCase
+	Default *Block
+}
+
+func (o *SelectS) String() string {
+	return fmt.Sprintf("\nSelect(cases: [[[ %#v ]]], default: %v )\n", o.Cases, o.Default)
+}
+
+func (o *SelectS) VisitStmt(v StmtVisitor) {
+	v.VisitSelect(o)
+}
+
 type WhileS struct {
 	First Stmt
 	Pred  Expr
@@ -924,13 +1239,13 @@ type FuncRecX struct {
 }
 
 type FuncRec struct {
-	Ins             []NameTV
-	Outs            []NameTV
-	HasDotDotDot    bool
-	IsMethod        bool
-	TODO_PtrTypedef string // global typedef of a pointer to this function type.
-	FuncRecX        *FuncRecX
-	gdef            *GDef // needed for dispatching method
+	Ins          []NameTV
+	Outs         []NameTV
+	HasDotDotDot bool
+	IsMethod     bool
+	PtrTypedef   string // global typedef of a pointer to this function type.
+	FuncRecX     *FuncRecX
+	gdef         *GDef // needed for dispatching method
 }
 
 func (r *FuncRec) SignatureStr(daFunc string, addReceiver bool) string {
@@ -1041,22 +1356,27 @@ func (b *Block) VisitStmt(v StmtVisitor) {
 
 type MarkInfo struct {
 	size int  // the size of the field, accumulate for offset.
-	mark bool // this position (before adding the size) has a handle to be marked.
+	mark bool // this field has a handle to be marked.
+	at   int  // where the handle is in the field.
 }
 
 var markInfo = map[byte]*MarkInfo{
-	'z': &MarkInfo{1, false},
-	'b': &MarkInfo{1, false},
-	'i': &MarkInfo{2, false},
-	'u': &MarkInfo{2, false},
-	'k': &MarkInfo{2, false},
-
-	's': &MarkInfo{6, true},
-	'S': &MarkInfo{6, true},
-	'P': &MarkInfo{2, true},
-	'I': &MarkInfo{2, true},
-	'F': &MarkInfo{2, false},
-	't': &MarkInfo{2, false},
+	'z': &MarkInfo{1, false, 0},
+	'b': &MarkInfo{1, false, 0},
+	'i': &MarkInfo{2, false, 0},
+	'u': &MarkInfo{2, false, 0},
+	'p': &MarkInfo{2, false, 0},
+	'k': &MarkInfo{2, false, 0},
+
+	's': &MarkInfo{6, true, 0},
+	'S': &MarkInfo{6, true, 0},
+	'P': &MarkInfo{2, true, 0},
+	'I': &MarkInfo{2, true, 0},
+	'M': &MarkInfo{2, true, 0},
+	'C': &MarkInfo{2, true, 0},
+	'a': &MarkInfo{4, false, 0},
+	'F': &MarkInfo{4, true, 2}, // the env, which may be a heap object.
+	't': &MarkInfo{2, false, 0},
 }
 
 /*
@@ -1167,6 +1487,7 @@ func (o *GDef) ResolveAsTypeValue() (TypeValue, bool) {
 	return nil, false
 }
 func (o *CVal) ResolveAsTypeValue() (TypeValue, bool)           { return nil, false }
+func (o *StringConstVal) ResolveAsTypeValue() (TypeValue, bool) { return nil, false }
 func (o *SubVal) ResolveAsTypeValue() (TypeValue, bool)         { return nil, false }
 func (o *ImportVal) ResolveAsTypeValue() (TypeValue, bool)      { return nil, false }
 func (o *BoundMethodVal) ResolveAsTypeValue() (TypeValue, bool) { return nil, false }
@@ -1185,6 +1506,11 @@ type CVal struct {
 	t TypeValue
 }
 
+// StringConstVal is a constant string, known at compile time.
+type StringConstVal struct {
+	s string
+}
+
 type SubVal struct {
 	container Value
 	subscript Value
@@ -1218,15 +1544,26 @@ func (val *BoundMethodVal) String() string {
 func (val *CVal) Type() TypeValue {
 	return val.t
 }
+func (val *StringConstVal) String() string {
+	return Format("(%q:StringConstVal)", val.s)
+}
+func (val *StringConstVal) Type() TypeValue {
+	return StringTO
+}
+func (val *StringConstVal) ToC() string {
+	return Format("MakeString(%s, %d)", CStringLit(val.s), len(val.s))
+}
 func (val *TypeVal) Type() TypeValue {
 	return TypeTO
 }
 func (val *SubVal) Type() TypeValue {
-	switch t := val.container.Type().(type) {
+	switch t := Underlying(val.container.Type()).(type) {
 	case *SliceTV:
 		return t.E
+	case *ArrayTV:
+		return t.E
 	case *MapTV:
-		return t.K
+		return t.V
 	}
 	switch val.container.Type().TypeCode()[0] {
 	case 's':
@@ -1234,8 +1571,19 @@ func (val *SubVal) Type() TypeValue {
 	}
 	panic(F("L1169: cannot index into %v", val.container))
 }
+
+// Type of a method value omits the receiver,
+// which is bound.  Calls use mtype directly.
 func (val *BoundMethodVal) Type() TypeValue {
-	return val.mtype
+	if val.isFace {
+		return val.mtype // face already omits receiver from FuncRec
+	}
+	rec := val.mtype.(*FunctionTV).FuncRec
+	return &FunctionTV{&FuncRec{
+		Ins:          rec.Ins[1:],
+		Outs:         rec.Outs,
+		HasDotDotDot: rec.HasDotDotDot,
+	}}
 }
 
 func (val *CVal) ToC() string {
@@ -1246,7 +1594,7 @@ func (val *TypeVal) ToC() string {
 }
 func (val *SubVal) ToC() string {
 	ser := Serial("sub")
-	switch t := val.container.Type().(type) {
+	switch t := Underlying(val.container.Type()).(type) {
 	case *SliceTV:
 		nth, ok := ResolveAsIntStr(val.subscript)
 		if !ok {
@@ -1260,8 +1608,19 @@ func (val *SubVal) ToC() string {
 			nth,
 			tmp.ToC())
 		return F("(%s /*L1196*/)", tmp.ToC())
+	case *ArrayTV:
+		// An element of an array is an lvalue.
+		return F("(%s).a[%s]", val.container.ToC(), ArrayIndexC(val.subscript, t.N))
 	case *MapTV:
-		panic(1198)
+		key := coHack.ReifyAs(val.subscript, t.K)
+		tmp := coHack.DefineLocalTempC(ser, t.V, "")
+		// bool MapGet(Map a, void* key, void* value, int vsize);
+		coHack.P("MapGet(%s, &%s, &%s, sizeof(%s)); //L1200",
+			val.container.ToC(),
+			key.ToC(),
+			tmp.ToC(),
+			t.V.CType())
+		return F("(%s /*L1205*/)", tmp.ToC())
 	}
 	switch val.container.Type().TypeCode()[0] {
 	case 's':
@@ -1279,6 +1638,23 @@ func (val *SubVal) ToC() string {
 	}
 	panic(F("L1188: cannot index into %v", val.container))
 }
+
+// ArrayIndexC is C for the index i into an array of length n,
+// checked at compile time if it is constant, or else at run time.
+func ArrayIndexC(i Value, n int) string {
+	if k, ok := ConstInt(i); ok {
+		if k < 0 || k >= int64(n) {
+			panic(F("invalid array index %d (out of bounds for %d-element array)", k, n))
+		}
+		return i.ToC()
+	}
+	nth, ok := ResolveAsIntStr(i)
+	if !ok {
+		panic(F("array subscript must be integer; got %v", i))
+	}
+	return F("CheckIndex(%s, %d)", nth, n)
+}
+
 func (val *BoundMethodVal) ToC() string {
 	panic(1243) // It's not that simple.
 }
@@ -1338,6 +1714,7 @@ func CompileToC(r io.Reader, sourceName string, w io.Writer, opt *Options) {
 	}
 
 	cg, cm := NewCGenAndMainCMod(opt, w)
+	cg.defs = pr
 	pr(`#include "runtime/runt.h"`)
 	pr(``)
 	if !opt.SkipBuiltin {
@@ -1356,17 +1733,20 @@ func CompileToC(r io.Reader, sourceName string, w io.Writer, opt *Options) {
 	}
 
 	dmap := make(map[string][]*FuncRec)
-	for _, gst := range cg.structs {
-		srec := gst.istype.(*StructTV).StructRec
-		for _, me := range srec.Meths {
+	for _, cname := range cg.classes {
+		meths, ok := cg.MethsOfClass(cname)
+		if !ok {
+			continue
+		}
+		for _, me := range meths {
 			frec := me.TV.(*FunctionTV).FuncRec
 			tc := frec.BuildTypeCode(true /*omitFirst*/)
 
 			dspec := CName(me.name, tc)
-			pr("// struct %q meth %q dspec %q", gst.CName, gst.name, dspec)
+			pr("// class %q meth %q dspec %q", cname, me.name, dspec)
 
 			if _, ok := cg.dmeths[dspec]; ok {
-				pr("// YES %q ::: %v", dspec, gst.CName)
+				pr("// YES %q ::: %v", dspec, cname)
 				dmap_dmeth, _ := dmap[dspec]
 				dmap[dspec] = append(dmap_dmeth, frec)
 				pr("// DMAP ::: %v", dmap)
@@ -1378,27 +1758,112 @@ func CompileToC(r io.Reader, sourceName string, w io.Writer, opt *Options) {
 		pr("// EmitDispatch ::: %q", dspec)
 		cg.EmitDispatch(dspec, recs)
 	}
+	for _, irec := range cg.implements {
+		pr("// EmitImplements ::: %q", irec.cname)
+		cg.EmitImplements(irec)
+	}
 }
 
-func (cg *CGen) EmitDispatch(dspec string, recs []*FuncRec) {
-	rt := F("rt_Dispatch__%s", dspec)
+// MethsOfClass gives the methods of the struct, or of the
+// named type, whose objects (or boxes) have the heap class cname.
+func (cg *CGen) MethsOfClass(cname string) ([]NameTV, bool) {
+	if gst, ok := cg.structs[cname]; ok {
+		return gst.istype.(*StructTV).StructRec.Meths, true
+	}
+	if nt, ok := cg.named[cname]; ok {
+		return nt.Meths, true
+	}
+	return nil, false
+}
+
+// EmitImplements writes the C func that tells whether the
+// struct or box with a class number (from ocls) has all the methods
+// of the interface, for runtime type switches and assertions.
+func (cg *CGen) EmitImplements(irec *InterfaceRec) {
 	s := F(`
 #include "___.defs.h"
-%s Dispatch__%s(void* p) {
-  byte cls = ocls((word)p);
+bool Implements__%s(byte cls) {
 	switch (cls) {
-`, rt, dspec)
+`, irec.cname)
+	for _, cname := range cg.classes {
+		meths, ok := cg.MethsOfClass(cname)
+		if !ok {
+			continue
+		}
+		if MethsImplement(meths, irec) {
+			s += F("case CLASS_%s:\n", cname)
+		}
+	}
+	s += F("return 1;\n")
+	s += F("}\n")
+	s += F("return 0;\n")
+	s += F("}\n")
+
+	filename := F("___.Implements.%s.c", irec.cname)
+	err := ioutil.WriteFile(filename, []byte(s), 0777)
+	if err != nil {
+		panic(F("cannot WriteFile %q: %v", filename, err))
+	}
+}
+
+// MethsImplement tells if the methods of a struct (or named type)
+// include every method of the interface, with the same signature.
+func MethsImplement(meths []NameTV, irec *InterfaceRec) bool {
+	return MissingMethod(meths, true /*omitFirst*/, irec) == ""
+}
+
+// MissingMethod names the first method of the interface that is
+// not in meths with the same signature, or is "" if none is missing.
+// Methods of an interface have no receiver, so omitFirst is false for them.
+func MissingMethod(meths []NameTV, omitFirst bool, irec *InterfaceRec) string {
+	for _, im := range irec.Meths {
+		itc := im.TV.(*FunctionTV).FuncRec.BuildTypeCode(false)
+		found := false
+		for _, sm := range meths {
+			if sm.name == im.name {
+				stc := sm.TV.(*FunctionTV).FuncRec.BuildTypeCode(omitFirst)
+				found = (stc == itc)
+				break
+			}
+		}
+		if !found {
+			return im.name
+		}
+	}
+	return ""
+}
+
+func (cg *CGen) EmitDispatch(dspec string, recs []*FuncRec) {
+	rt := F("rt_Dispatch__%s", dspec)
+	var boxed string
+	cases := ""
 	for _, rec := range recs {
 		rcvr := rec.Ins[0]
-		srec := rcvr.TV.(*PointerTV).E.(*StructTV).StructRec
-		cnum, ok := cg.classNums[srec.cname]
+		var cname string
+		callme := rec.gdef.CName
+		if nt, ok := rcvr.TV.(*NamedTV); ok {
+			cname = nt.cname
+			callme += "__boxed"
+			boxed += BoxedMethodC(callme, rec, nt)
+		} else {
+			cname = rcvr.TV.(*PointerTV).E.(*StructTV).StructRec.cname
+		}
+		cnum, ok := cg.classNums[cname]
 		if !ok {
-			L("WARNING: cannot find classNum for %q", srec.cname)
+			L("WARNING: cannot find classNum for %q", cname)
 		}
 
-		s += F("case %d: return (%s)%s;\n", cnum, rt, rec.gdef.CName)
+		cases += F("case %d: return (%s)%s;\n", cnum, rt, callme)
 
 	}
+	s := F(`
+#include "___.defs.h"
+%s
+%s Dispatch__%s(void* p) {
+  byte cls = ocls((word)p);
+	switch (cls) {
+`, boxed, rt, dspec)
+	s += cases
 	s += F("}\n")
 	s += F("panic_s(\"bad dispatch for %s\");", dspec)
 	s += F("return 0;")
@@ -1411,6 +1876,44 @@ func (cg *CGen) EmitDispatch(dspec string, recs []*FuncRec) {
 	}
 }
 
+// BoxedMethodC is C for a func named callme that calls the
+// method rec of the named type nt, with the receiver taken
+// from the box it points to, so it is called like a method
+// on a struct pointer.
+func BoxedMethodC(callme string, rec *FuncRec, nt *NamedTV) string {
+	return ForwardingC(callme, rec, rec.gdef.CName, F("*(%s*)receiver", nt.CType()))
+}
+
+// ForwardingC is C for a func named callme that takes a void*
+// first, like the fn of a Func value, and calls the func target
+// with its other params.  If self is not empty, it is passed
+// to target in place of the first input of rec, the receiver.
+func ForwardingC(callme string, rec *FuncRec, target string, self string) string {
+	params := []string{"void* receiver"}
+	var args []string
+	ins := rec.Ins
+	if self != "" {
+		args = append(args, self)
+		ins = ins[1:]
+	}
+	for i, in := range ins {
+		params = append(params, F("%s in_%d", in.TV.CType(), i))
+		args = append(args, F("in_%d", i))
+	}
+	result, ret := "void", ""
+	if len(rec.Outs) == 1 {
+		result, ret = rec.Outs[0].TV.CType(), "return "
+	} else {
+		for i, out := range rec.Outs {
+			params = append(params, F("%s *out_%d", out.TV.CType(), i))
+			args = append(args, F("out_%d", i))
+		}
+	}
+	return F("static %s %s(%s) {\n  %s%s(%s);\n}\n",
+		result, callme, strings.Join(params, ", "),
+		ret, target, strings.Join(args, ", "))
+}
+
 func (cm *CMod) defineOnce(g *GDef) {
 	if g.name == "init" {
 		ser := Serial("init__mod_")
@@ -1440,6 +1943,7 @@ func (cm *CMod) FirstSlotGlobals(p *Parser, pr printer) {
 	}
 	for _, g := range p.Consts {
 		cm.defineOnce(g)
+		g.constMod = cm
 	}
 	for _, g := range p.Vars {
 		cm.defineOnce(g)
@@ -1476,48 +1980,36 @@ func (cm *CMod) SecondBuildGlobals(p *Parser, pr printer) {
 			rec := t.StructRec
 			cname := CName(cm.Package, rec.name)
 			rec.cname = cname
-			cg := cm.CGen
-			if _, already := cg.classNums[cname]; already {
-				panic(F("struct already defined: %s", cname))
-			}
-			num := len(cg.classes)
-			cg.classNums[cname] = num
-			cg.classes = append(cg.classes, cname)
-			pr("#define CLASS_%s %d", cname, num)
+			cm.CGen.RegisterClass(cname, pr)
 			pr("struct %s; // L1334", cname, cname)
 
-			// Figure out the GC mark shape.
-			// Bytes represent offsets from 1 byte before
-			// the start of the struct
-			// to mark points (where Handles are).
-			// We start with offset 1 (but reset
-			// to offset 0), so an initial 0 cannot be needed
-			// if the very first byte of the struct is a mark point.
-			var shape []byte
-			offset := 1
+			var fieldTypes []TypeValue
 			for i, e := range rec.Fields {
 				pr("// [%d] %#v", i, e)
-				tc := e.TV.TypeCode()
-				info := markInfo[tc[0]]
-				if info.mark {
-					assert(offset < 250)
-					shape = append(shape, byte(offset))
-					offset = 0
-				}
-				pr("//     %d: %q", offset, tc)
-				offset += info.size
+				fieldTypes = append(fieldTypes, e.TV)
 			}
-			rec.shape = string(shape)
+			rec.shape = MarkShape(0, fieldTypes)
 			pr("#define SHAPE_%s %q", cname, rec.shape)
 
 		case *InterfaceTV:
 			t.InterfaceRec.cname = CName(cm.Package, t.InterfaceRec.name)
+
+		default:
+			// A defined type over anything else gets its own
+			// identity, and a class for its boxes.
+			nt := &NamedTV{
+				name:  g.name,
+				cname: g.CName,
+				E:     Underlying(t),
+			}
+			g.istype = nt
+			cm.CGen.named[nt.cname] = nt
+			cm.CGen.RegisterClass(nt.cname, pr)
+			pr("#define SHAPE_%s %q", nt.cname, MarkShape(0, []TypeValue{nt.E}))
 		}
 	}
 	for _, g := range p.Consts {
-		Say(g.Package, g.name, "2C")
-		// not allowing g.Type on constants.
-		g.constval = g.initx.VisitExpr(cm.QuickCompiler(g))
+		cm.EvalConst(g)
 	}
 	for _, g := range p.Vars {
 		Say(g.Package, g.name, "2V")
@@ -1533,7 +2025,143 @@ func (cm *CMod) SecondBuildGlobals(p *Parser, pr printer) {
 	}
 }
 
-func (cm *CMod) StructRecOfReceiverOfFuncX(funcX *FunctionX) *StructRec {
+// ArrayType gives the array type of n elements of type e.
+// In C it is a struct holding the array, so it can be copied
+// by assignment, so the typedef is emitted the first time
+// the array type is seen.
+func (cg *CGen) ArrayType(n int, e TypeValue) *ArrayTV {
+	cname := F("Array_%d_%s", n, CIdent(e.CType()))
+	if at, ok := cg.arrays[cname]; ok {
+		return at
+	}
+	at := &ArrayTV{N: n, E: e, cname: cname}
+	cg.arrays[cname] = at
+	cg.defs("typedef struct { %s a[%d]; } %s; // L1790", e.CType(), n, cname)
+	return at
+}
+
+// CIdent replaces the characters of s that cannot be in a C identifier.
+func CIdent(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// FuncPtrType is the name of a typedef of a pointer to a C func
+// for calling a Func value with signature rec, taking its env first.
+func (cg *CGen) FuncPtrType(rec *FuncRec) string {
+	if rec.PtrTypedef != "" {
+		return rec.PtrTypedef
+	}
+	sig := rec.SignatureStr("(*)", true /*addReceiver*/)
+	name, ok := cg.funcPtrTypedefs[sig]
+	if !ok {
+		name = F("FuncPtr_%d", len(cg.funcPtrTypedefs)+1)
+		cg.funcPtrTypedefs[sig] = name
+		cg.dynamicDefs = append(cg.dynamicDefs,
+			F("typedef %s; // L1812", rec.SignatureStr("(*"+name+")", true /*addReceiver*/)))
+	}
+	rec.PtrTypedef = name
+	return name
+}
+
+// RegisterClass gives the next heap class number to cname,
+// for structs and for boxes of named types.
+func (cg *CGen) RegisterClass(cname string, pr printer) int {
+	if _, already := cg.classNums[cname]; already {
+		panic(F("type already defined: %s", cname))
+	}
+	num := len(cg.classes)
+	cg.classNums[cname] = num
+	cg.classes = append(cg.classes, cname)
+	pr("#define CLASS_%s %d", cname, num)
+	return num
+}
+
+// EvalConst evaluates the const g, if not done yet.
+// Consts are evaluated when first used, so they can be used
+// before they are defined, even in types like `[N]byte`.
+func (cm *CMod) EvalConst(g *GDef) {
+	if g.constval != nil {
+		return
+	}
+	if g.evaluating {
+		panic(F("const %s refers to itself", g.name))
+	}
+	g.evaluating = true
+	Say(g.Package, g.name, "2C")
+	qc := cm.QuickCompiler(g)
+	qc.iota = KVal(int64(g.iota))
+	val := g.initx.VisitExpr(qc)
+	if !IsConst(val) {
+		panic(F("const %s: not a constant: %v", g.name, val))
+	}
+	if g.typex != nil {
+		tv, ok := g.typex.VisitExpr(qc).ResolveAsTypeValue()
+		if !ok {
+			panic(F("const %s: expected a type, got %v", g.name, g.typex))
+		}
+		val = ConvertConst(val, tv)
+	}
+	if val.Type() == ConstIntTO {
+		val = KVal(EvalK(val))
+	}
+	g.constval = val
+	g.evaluating = false
+}
+
+// MarkShape figures out the GC mark shape for fields of the given types,
+// which start `skip` bytes into a GC Heap object.
+// Bytes represent offsets from 1 byte before
+// the start of the object
+// to mark points (where Handles are).
+// We start with offset 1 (but reset
+// to offset 0), so an initial 0 cannot be needed
+// if the very first byte of the object is a mark point.
+func MarkShape(skip int, types []TypeValue) string {
+	var shape []byte
+	offset := 1 + skip
+	for _, tv := range ArrayElements(types) {
+		tc := tv.TypeCode()
+		info := markInfo[tc[0]]
+		if info.mark {
+			offset += info.at
+			assert(offset < 250)
+			shape = append(shape, byte(offset))
+			offset = -info.at
+		}
+		offset += info.size
+	}
+	return string(shape)
+}
+
+// MethsOfReceiverOfFuncX gives the cname of the type that
+// the method funcX is for, and the methods of that type, which are
+// on the struct for a pointer receiver, or on the named type for
+// a value receiver.
+// ArrayElements replaces each array in types with its elements,
+// which are laid out one after another, for figuring mark shapes.
+func ArrayElements(types []TypeValue) []TypeValue {
+	var z []TypeValue
+	for _, tv := range types {
+		if at, ok := Underlying(tv).(*ArrayTV); ok {
+			for i := 0; i < at.N; i++ {
+				z = append(z, ArrayElements([]TypeValue{at.E})...)
+			}
+		} else {
+			z = append(z, tv)
+		}
+	}
+	return z
+}
+
+func (cm *CMod) MethsOfReceiverOfFuncX(funcX *FunctionX) (string, *[]NameTV) {
 	rec := funcX.FuncRecX
 	assert(rec.IsMethod)
 	assert(len(rec.Ins) > 0)
@@ -1546,6 +2174,9 @@ func (cm *CMod) StructRecOfReceiverOfFuncX(funcX *FunctionX) *StructRec {
 	if !ok {
 		panic(F("L1309: expected a type for method receiver, but got %v", r))
 	}
+	if nt, ok := tv.(*NamedTV); ok {
+		return nt.cname, &nt.Meths
+	}
 	pointerType, ok := tv.(*PointerTV)
 	if !ok {
 		panic(F("L1313: Expected pointer to struct as method receiver; got %v", r.Type()))
@@ -1556,7 +2187,7 @@ func (cm *CMod) StructRecOfReceiverOfFuncX(funcX *FunctionX) *StructRec {
 		panic(F("L1318: Expected pointer to struct as method receiver; got %v", r.Type()))
 	}
 	Say(structType, structType)
-	return structType.StructRec
+	return structType.StructRec.cname, &structType.StructRec.Meths
 }
 
 func (cm *CMod) ThirdDefineGlobals(p *Parser, pr printer) {
@@ -1609,10 +2240,10 @@ func (cm *CMod) ThirdDefineGlobals(p *Parser, pr printer) {
 		g.typeof = &FunctionTV{funcRec}
 		Say("Got Third Meths:", g)
 
-		// Install meth on struct.
-		structRec := cm.StructRecOfReceiverOfFuncX(funcX)
-		structRec.Meths = append(structRec.Meths, NameTV{g.name, g.typeof})
-		g.CName = CName(structRec.cname, g.name)
+		// Install meth on struct or named type.
+		cname, meths := cm.MethsOfReceiverOfFuncX(funcX)
+		*meths = append(*meths, NameTV{g.name, g.typeof})
+		g.CName = CName(cname, g.name)
 	}
 }
 
@@ -1866,6 +2497,10 @@ type GDef struct {
 	istype   TypeValue
 	typeof   TypeValue
 	constval Value
+	iota     int // for Const, its index in its group.
+
+	constMod   *CMod // for Const, its module.
+	evaluating bool  // for Const, while evaluating it.
 }
 
 type Scope interface {
@@ -1912,12 +2547,17 @@ type CGen struct {
 
 	structs map[string]*GDef
 	faces   map[string]*GDef
+	named   map[string]*NamedTV // by cname, for boxing into interfaces.
+	arrays  map[string]*ArrayTV // by cname of the typedef.
+	defs    printer             // onto ___.defs.h
 
 	classes            []string
 	classNums          map[string]int
 	dmeths             map[string][]string // dsig -> unique interfaces that dispatch it.
 	dynamicDefs        []string            // late Dynamic declarations.
 	dispatcherTypedefs map[string]bool
+	funcPtrTypedefs    map[string]string        // C signature -> typedef name.
+	implements         map[string]*InterfaceRec // face cname -> face, for runtime checks.
 }
 
 func NewCMod(name string, cg *CGen) *CMod {
@@ -1937,13 +2577,17 @@ func NewCGenAndMainCMod(opt *Options, w io.Writer) (*CGen, *CMod) {
 
 		structs: make(map[string]*GDef),
 		faces:   make(map[string]*GDef),
+		named:   make(map[string]*NamedTV),
+		arrays:  make(map[string]*ArrayTV),
 
 		classes: []string{
-			"_FREE_", "_BYTES_", "_HANDLES_", "_STRINGS_", "_SLICES_",
+			"_FREE_", "_BYTES_", "_HANDLES_", "_STRINGS_", "_SLICES_", "_MAP_", "_CHAN_",
 		},
 		classNums:          make(map[string]int),
 		dmeths:             make(map[string][]string),
 		dispatcherTypedefs: make(map[string]bool),
+		funcPtrTypedefs:    make(map[string]string),
+		implements:         make(map[string]*InterfaceRec),
 	}
 	cg.Prims = &CMod{
 		Package: "", // Use empty package name for Prims.
@@ -1962,6 +2606,13 @@ func NewCGenAndMainCMod(opt *Options, w io.Writer) (*CGen, *CMod) {
 			UsedBy: nil,
 		}
 	}
+	// A rune is an int, which holds 16 bits on the 6809.
+	cg.Prims.Members["rune"] = &GDef{
+		name:   "rune",
+		CName:  "P_int",
+		istype: IntTO,
+		typeof: TypeTO,
+	}
 	cg.Prims.Members["nil"] = NIL
 	cg.Prims.Members["true"] = TRUE
 	cg.Prims.Members["false"] = FALSE
@@ -2014,17 +2665,26 @@ type DeferRec struct {
 }
 
 type Compiler struct {
-	CMod         *CMod
-	CGen         *CGen
-	Subject      *GDef
-	BreakTo      string
-	ContinueTo   string
-	CurrentBlock *Block
-	Defers       []*DeferRec
-	Buf          *Buf
-	slots        map[string]*GDef // not really G
-	classes      []string
-	results      []NameTV // remembers return variables, if >1
+	CMod          *CMod
+	CGen          *CGen
+	Subject       *GDef
+	BreakTo       string
+	ContinueTo    string
+	FallthroughTo string // the next case, while compiling a case ending in `fallthrough`.
+	CurrentBlock  *Block
+	Defers        []*DeferRec
+	Buf           *Buf
+	slots         map[string]*GDef // not really G
+	classes       []string
+	results       []NameTV  // remembers return variables, if >1
+	namedResults  []*GDef   // locals for named results, if any
+	Outer         *Compiler // the enclosing func, if compiling a lambda
+	lambdas       []string  // C code of lambdas defined in this func
+	thunks        []string  // C code of thunks for `go` in this func
+	funcBlock     *Block    // the scope of the func, outside its body
+	deferCount    *GDef     // how many Defers have been reached, at runtime
+	zeroResult    *GDef     // returned if a panic is recovered
+	iota          Value     // the value of iota, while evaluating a const
 }
 
 func NewCompiler(cm *CMod, subject *GDef) *Compiler {
@@ -2053,20 +2713,127 @@ func (co *Compiler) VisitLitInt(x *LitIntX) Value {
 	return z
 }
 func (co *Compiler) VisitLitString(x *LitStringX) Value {
-	return &CVal{
-		c: Format("MakeStringFromC(%q)", x.X),
-		t: StringTO,
+	return &StringConstVal{x.X}
+}
+
+// CStringLit quotes s for C.  Other than printable ASCII,
+// bytes are 3-digit octal escapes, because C's \x escapes
+// would swallow any hex digits that follow.
+func CStringLit(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\' || c == '?': // `?` because of trigraphs.
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case ' ' <= c && c <= '~':
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "\\%03o", c)
+		}
 	}
+	buf.WriteByte('"')
+	return buf.String()
 }
+
 func (co *Compiler) VisitIdent(x *IdentX) Value {
 	L("VisitIdent: %s", x.X)
-	return co.FindName(x.X)
+	if co.iota != nil {
+		// Evaluating a const.
+		if x.X == "iota" {
+			return co.iota
+		}
+	}
+	return ConstOrDef(co.FindName(x.X))
+}
+
+// ConstOrDef gives the value of a GDef, which is its constval if it is a const.
+func ConstOrDef(gd *GDef) Value {
+	if gd.constMod != nil {
+		gd.constMod.EvalConst(gd)
+		return gd.constval
+	}
+	return gd
 }
 func (co *Compiler) VisitBinOp(x *BinOpX) Value {
+	if x.Op == "&&" || x.Op == "||" {
+		return co.VisitShortCircuit(x)
+	}
 	a := x.A.VisitExpr(co)
-	op := x.Op
 	b := x.B.VisitExpr(co)
-	var resultType TypeValue
+	return co.BinOp(a, x.Op, b)
+}
+
+// VisitShortCircuit emits the statements for the right side
+// of `&&` or `||` inside an `if`, so they only run when needed.
+func (co *Compiler) VisitShortCircuit(x *BinOpX) Value {
+	a := x.A.VisitExpr(co)
+	if a.Type() != BoolTO {
+		panic(F("operator %s needs bool operands, got %v", x.Op, a))
+	}
+	if a == TRUE || a == FALSE {
+		if (a == TRUE) == (x.Op == "||") {
+			return a // The right side is not needed.
+		}
+		return BoolOperand(x.Op, x.B.VisitExpr(co))
+	}
+	z := co.DefineLocalTempV(Serial("logic"), BoolTO, a)
+	if x.Op == "&&" {
+		co.P("if (%s) { // L2298: &&", z.CName)
+	} else {
+		co.P("if (!%s) { // L2300: ||", z.CName)
+	}
+	b := BoolOperand(x.Op, x.B.VisitExpr(co))
+	co.P("%s = %s;", z.CName, b.ToC())
+	co.P("}")
+	return z
+}
+
+func BoolOperand(op string, b Value) Value {
+	if b.Type() != BoolTO {
+		panic(F("operator %s needs bool operands, got %v", op, b))
+	}
+	return b
+}
+
+func (co *Compiler) VisitUnaryOp(x *UnaryX) Value {
+	a := x.X.VisitExpr(co)
+	switch x.Op {
+	case "!":
+		if a.Type() != BoolTO {
+			panic(F("operator ! needs a bool operand, got %v", a))
+		}
+		switch a {
+		case TRUE:
+			return FALSE
+		case FALSE:
+			return TRUE
+		}
+		return &CVal{
+			c: Format("(/*L2323*/!(%s))", a.ToC()),
+			t: BoolTO,
+		}
+	case "^":
+		switch a.Type().TypeCode() {
+		case "k":
+			return KVal(^EvalK(a))
+		case "b", "i", "u", "p":
+			return &CVal{
+				c: Format("(%s)(/*L2332*/~(%s))", a.Type().CType(), a.ToC()),
+				t: a.Type(),
+			}
+		}
+		panic(F("operator ^ needs an integer operand, got %v", a))
+	}
+	panic(F("unknown unary operator %q", x.Op))
+}
+
+// BinOp applies the binary operator, other than `&&` and `||`,
+// to values already evaluated.
+func (co *Compiler) BinOp(a Value, op string, b Value) Value {
+	var resultType TypeValue
 
 	L("BinOp: a = %#v", a)
 	L("BinOp: b = %#v", b)
@@ -2075,6 +2842,19 @@ func (co *Compiler) VisitBinOp(x *BinOpX) Value {
 
 	switch op {
 	case "==", "!=", "<", "<=", ">", ">=":
+		// A func can only be compared with nil.
+		if _, ok := Underlying(b.Type()).(*FunctionTV); ok && a.Type() == NilTO {
+			a, b = b, a
+		}
+		if _, ok := Underlying(a.Type()).(*FunctionTV); ok {
+			if b.Type() != NilTO || op != "==" && op != "!=" {
+				panic(F("func can only be compared to nil"))
+			}
+			return &CVal{
+				c: Format("(/*L1854*/(%s).fn %s (FuncPtr)0)", co.FuncValue(a).ToC(), op),
+				t: BoolTO,
+			}
+		}
 		switch ta := a.Type().(type) {
 		case *InterfaceTV:
 			switch tb := b.Type().(type) {
@@ -2114,6 +2894,13 @@ func (co *Compiler) VisitBinOp(x *BinOpX) Value {
 
 		case *PrimTV:
 			switch ta.typecode {
+			case "a": // interface{}
+				if b.Type() == NilTO {
+					return &CVal{
+						c: Format("(/*L1840*/(%s).typecode %s (void*)0)", a.ToC(), op),
+						t: BoolTO,
+					}
+				}
 			case "n": // nil
 				switch b.Type().(type) {
 				case *InterfaceTV:
@@ -2135,6 +2922,7 @@ func (co *Compiler) VisitBinOp(x *BinOpX) Value {
 	if a.Type().TypeCode() == "k" {
 		switch b.Type().TypeCode() {
 		case "b", "i", "u", "p":
+			CheckConstFits(a, b.Type())
 			a = &CVal{a.ToC(), b.Type()}
 		case "k":
 			// Both a and b are ConstInt: return a computed ConstInt.
@@ -2155,6 +2943,8 @@ func (co *Compiler) VisitBinOp(x *BinOpX) Value {
 				return KVal(EvalK(a) | EvalK(b))
 			case "^":
 				return KVal(EvalK(a) ^ EvalK(b))
+			case "&^":
+				return KVal(EvalK(a) &^ EvalK(b))
 
 			case "<<":
 				return KVal(EvalK(a) << EvalK(b))
@@ -2177,18 +2967,55 @@ func (co *Compiler) VisitBinOp(x *BinOpX) Value {
 		}
 	}
 
+	if op == "<<" || op == ">>" {
+		// The shift count may be any integer type.
+		if a.Type().TypeCode() == "k" {
+			a = &CVal{a.ToC(), IntTO}
+		}
+		switch a.Type().TypeCode() {
+		case "b", "i", "u", "p":
+			x, ok1 := ConstInt(a)
+			y, ok2 := ConstInt(b)
+			if ok1 && ok2 && y >= 0 {
+				if op == "<<" {
+					return TypedK(x<<y, a.Type())
+				}
+				return TypedK(x>>y, a.Type())
+			}
+			switch b.Type().TypeCode() {
+			case "k", "b", "i", "u", "p":
+				return &CVal{
+					c: Format("(%s)(/*L2406*/(%s) %s (%s))", a.Type().CType(), a.ToC(), op, b.ToC()),
+					t: a.Type(),
+				}
+			}
+		}
+		panic(F("cannot shift %v by %v", a, b))
+	}
+
 	if b.Type().TypeCode() == "k" {
 		switch a.Type().TypeCode() {
 		case "b", "i", "u", "p":
+			CheckConstFits(b, a.Type())
 			b = &CVal{b.ToC(), a.Type()}
 		}
 	}
 
+	if z, ok := FoldConst(a, op, b); ok {
+		return z
+	}
+
 	if a.Type().Equals(b.Type()) {
 		switch a.Type().TypeCode() {
 		case "b", "i", "u", "p":
+			if op == "&^" {
+				return &CVal{
+					c: Format("(%s)(/*L2425*/(%s) & ~(%s))", a.Type().CType(), a.ToC(), b.ToC()),
+					t: a.Type(),
+				}
+			}
 			switch op {
-			case "+", "-", "*", "/", "%":
+			case "+", "-", "*", "/", "%", "&", "|", "^":
 				resultType = a.Type()
 			case "==", "!=", "<", "<=", ">", ">=":
 				resultType = BoolTO
@@ -2264,6 +3091,165 @@ func EvalK(a Value) int64 {
 	}
 	return z
 }
+
+// CheckConstFits panics if x is a ConstInt that
+// does not fit in the integer type tv on the target,
+// where int and uint are 16 bits.
+func CheckConstFits(x Value, tv TypeValue) {
+	if x.Type() != ConstIntTO {
+		return
+	}
+	var lo, hi int64
+	switch tv.TypeCode() {
+	case "b":
+		lo, hi = 0, 0xFF
+	case "i":
+		lo, hi = -0x8000, 0x7FFF
+	case "u", "p":
+		lo, hi = 0, 0xFFFF
+	default:
+		return
+	}
+	// A ConstInt that was reified into a temp is no longer known.
+	k, err := strconv.ParseInt(x.ToC(), 10, 64)
+	if err != nil {
+		return
+	}
+	if k < lo || k > hi {
+		panic(F("constant %d overflows %s", k, TypeName(tv)))
+	}
+}
+
+// IsConst tells if v is a constant, known at compile time:
+// a string, a bool, or an integer (untyped or typed).
+func IsConst(v Value) bool {
+	if v == TRUE || v == FALSE {
+		return true
+	}
+	if _, ok := v.(*StringConstVal); ok {
+		return true
+	}
+	_, ok := ConstInt(v)
+	return ok
+}
+
+// ConstInt gives the value of an integer constant.
+// It is constant if its C code is just a decimal number.
+func ConstInt(v Value) (int64, bool) {
+	switch v.Type().TypeCode() {
+	case "b", "i", "u", "k", "p":
+		k, err := strconv.ParseInt(v.ToC(), 10, 64)
+		return k, err == nil
+	}
+	return 0, false
+}
+
+// TypedK makes a typed integer constant, which must fit the type.
+func TypedK(k int64, tv TypeValue) Value {
+	CheckConstFits(KVal(k), tv)
+	return &CVal{F("%d", k), tv}
+}
+
+// ConvertConst gives the constant v as type tv,
+// which must be its own type, or v must be untyped.
+func ConvertConst(v Value, tv TypeValue) Value {
+	if v.Type().Equals(tv) {
+		return v
+	}
+	if k, ok := ConstInt(v); ok && v.Type() == ConstIntTO {
+		switch tv.TypeCode() {
+		case "b", "i", "u", "p":
+			return TypedK(k, tv)
+		}
+	}
+	panic(F("cannot use constant %s as %s", v.ToC(), TypeName(tv)))
+}
+
+// FoldConst applies op to constants a and b, if it can.
+// Integers have already been converted to the same type
+// (or both are untyped).
+func FoldConst(a Value, op string, b Value) (Value, bool) {
+	if x, ok := a.(*StringConstVal); ok {
+		if y, ok := b.(*StringConstVal); ok {
+			switch op {
+			case "+":
+				return &StringConstVal{x.s + y.s}, true
+			case "==":
+				return BVal(x.s == y.s), true
+			case "!=":
+				return BVal(x.s != y.s), true
+			case "<":
+				return BVal(x.s < y.s), true
+			case "<=":
+				return BVal(x.s <= y.s), true
+			case ">":
+				return BVal(x.s > y.s), true
+			case ">=":
+				return BVal(x.s >= y.s), true
+			}
+		}
+		return nil, false
+	}
+	if (a == TRUE || a == FALSE) && (b == TRUE || b == FALSE) {
+		switch op {
+		case "==":
+			return BVal(a == b), true
+		case "!=":
+			return BVal(a != b), true
+		}
+		return nil, false
+	}
+	x, ok1 := ConstInt(a)
+	y, ok2 := ConstInt(b)
+	if !ok1 || !ok2 || !a.Type().Equals(b.Type()) {
+		return nil, false
+	}
+	var z int64
+	switch op {
+	case "+":
+		z = x + y
+	case "-":
+		z = x - y
+	case "*":
+		z = x * y
+	case "/", "%":
+		if y == 0 {
+			panic(F("division by zero in constant %v %s %v", a, op, b))
+		}
+		if op == "/" {
+			z = x / y
+		} else {
+			z = x % y
+		}
+	case "&":
+		z = x & y
+	case "|":
+		z = x | y
+	case "^":
+		z = x ^ y
+	case "&^":
+		z = x &^ y
+	case "==":
+		return BVal(x == y), true
+	case "!=":
+		return BVal(x != y), true
+	case "<":
+		return BVal(x < y), true
+	case ">":
+		return BVal(x > y), true
+	case "<=":
+		return BVal(x <= y), true
+	case ">=":
+		return BVal(x >= y), true
+	default:
+		return nil, false
+	}
+	if a.Type() == ConstIntTO {
+		return KVal(z), true
+	}
+	return TypedK(z, a.Type()), true
+}
+
 func KVal(x int64) Value {
 	return &CVal{F("%d", x), ConstIntTO}
 }
@@ -2276,39 +3262,214 @@ func BVal(b bool) Value {
 }
 
 func (co *Compiler) VisitConstructor(ctorX *ConstructorX) Value {
-	tv := ctorX.typeX.VisitExpr(co)
-	g, ok := tv.(*GDef)
+	if ctorX.typeX == nil {
+		panic("L1758: missing type in composite literal")
+	}
+	tv, ok := ctorX.typeX.VisitExpr(co).ResolveAsTypeValue()
 	if !ok {
-		panic(F("L1767: Constructor must be for address of struct name: %s", tv))
+		panic(F("L1761: composite literal needs a type: %v", ctorX.typeX))
+	}
+	if structTV, ok := tv.(*StructTV); ok {
+		// Structs are only made with `&`, for a handle.
+		tv = &PointerTV{structTV}
 	}
+	return co.Construct(ctorX, tv)
+}
+
+// Construct builds the composite literal of type tv,
+// which is a pointer to struct, or a slice or array.
+func (co *Compiler) Construct(ctorX *ConstructorX, tv TypeValue) Value {
+	switch t := Underlying(tv).(type) {
+	case *PointerTV:
+		if structTV, ok := t.E.(*StructTV); ok {
+			return co.ConstructStruct(ctorX, tv, structTV.StructRec)
+		}
+	case *SliceTV:
+		n := len(ctorX.inits)
+		inst := co.DefineLocalTempC(Serial("slice"), tv,
+			F("MakeSlice(%q, %d, %d, sizeof(%s))", t.E.TypeCode(), n, n, t.E.CType()))
+		for i, e := range ctorX.inits {
+			if e.name != "" {
+				panic(F("L1790: keys are not supported in slice literal of type %s", TypeName(tv)))
+			}
+			val := co.ReifyAs(co.VisitElement(e.expr, t.E), t.E)
+			co.P("SlicePut(%s, sizeof(%s), %d, &%s); // L1793", inst.CName, t.E.CType(), i, val.ToC())
+		}
+		return inst
+	case *ArrayTV:
+		if len(ctorX.inits) > t.N {
+			panic(F("L1797: too many values in literal of type %s", TypeName(tv)))
+		}
+		inst := co.DefineLocalTempC(Serial("array"), tv, "")
+		co.P("memset(&%s, 0, sizeof %s); // L1800", inst.CName, inst.CName)
+		for i, e := range ctorX.inits {
+			if e.name != "" {
+				panic(F("L1803: keys are not supported in array literal of type %s", TypeName(tv)))
+			}
+			val := co.ReifyAs(co.VisitElement(e.expr, t.E), t.E)
+			co.P("(%s).a[%d] = %s; // L1806", inst.CName, i, val.ToC())
+		}
+		return inst
+	}
+	panic(F("L1810: cannot make composite literal of type %s", TypeName(tv)))
+}
 
-	if g.istype == nil {
-		panic(F("L1760: Constructor must be for address of struct: %s", g.CName))
+// VisitElement compiles an element of a composite literal of
+// elements of type tv.  The element may be a literal with its type
+// elided, like `{1, 2}` in `[][]int{{1, 2}}`.
+func (co *Compiler) VisitElement(x Expr, tv TypeValue) Value {
+	if ctorX, ok := x.(*ConstructorX); ok && ctorX.typeX == nil {
+		return co.Construct(ctorX, tv)
 	}
-	structTV, ok := g.istype.(*StructTV)
-	if !ok {
-		panic(F("L1764: Constructor must be for address of struct: %s", g.CName))
+	return x.VisitExpr(co)
+}
+
+// ConstructStruct allocates a struct, and sets its fields from
+// `name: value` elements, or from values for every field in order.
+func (co *Compiler) ConstructStruct(ctorX *ConstructorX, tv TypeValue, rec *StructRec) Value {
+	keyed := len(ctorX.inits) > 0 && ctorX.inits[0].name != ""
+	if !keyed && len(ctorX.inits) > 0 && len(ctorX.inits) != len(rec.Fields) {
+		panic(F("L1825: wrong number of values in struct literal of type %s: got %d, want %d",
+			rec.cname, len(ctorX.inits), len(rec.Fields)))
 	}
 
-	pointerTV := &PointerTV{structTV}
 	ser := Serial("ctor")
-	creation := F("(struct %s*) oalloc(sizeof(struct %s), CLASS_%s)", g.CName, g.CName, g.CName)
-	inst := co.DefineLocalTempC(ser, pointerTV, creation)
+	creation := F("(struct %s*) oalloc(sizeof(struct %s), CLASS_%s)", rec.cname, rec.cname, rec.cname)
+	inst := co.DefineLocalTempC(ser, tv, creation)
 
+	seen := make(map[string]bool)
 	for i, e := range ctorX.inits {
-		val := e.expr.VisitExpr(co)
-		co.P("  %s->f_%s = %s; //#%d L2018", inst.CName, e.name, val.ToC(), i)
+		if keyed != (e.name != "") {
+			panic(F("L1835: mixture of field:value and value elements in struct literal of type %s", rec.cname))
+		}
+		var field NameTV
+		if keyed {
+			ftype, ok := FindTypeByName(rec.Fields, e.name)
+			if !ok {
+				panic(F("L1841: unknown field %s in struct literal of type %s", e.name, rec.cname))
+			}
+			if seen[e.name] {
+				panic(F("L1843: duplicate field name %s in struct literal of type %s", e.name, rec.cname))
+			}
+			seen[e.name] = true
+			field = NameTV{e.name, ftype}
+		} else {
+			field = rec.Fields[i]
+		}
+		val := co.VisitElement(e.expr, field.TV)
+		co.P("  // field #%d L2018", i)
+		co.AssignField(inst, rec, field, val)
 	}
 
 	return inst
 }
+
+// AssignField sets the field of the new struct inst to val,
+// with the conversions of an assignment.
+// A failed conversion is reported with the field and struct
+// named, after its own message.
+func (co *Compiler) AssignField(inst *GDef, rec *StructRec, field NameTV, val Value) {
+	defer func() {
+		if r := recover(); r != nil {
+			why, ok := r.(string)
+			if !ok {
+				panic(r) // Not a compile error; keep it as it is.
+			}
+			panic(F("L1858: cannot use value of type %s as type %s in field %s of struct literal of type %s: %s",
+				GoTypeName(val.Type()), GoTypeName(field.TV), field.name, GoTypeName(inst.typeof), why))
+		}
+	}()
+	co.ConvertToCNameType(val, F("%s->f_%s", inst.CName, field.name), field.TV)
+}
+
+// VisitFunction compiles a lambda into a C function that
+// takes a pointer to the frame of the enclosing func, so it can use
+// the enclosing variables directly on the C stack.
+// The value of the lambda is a Func with that frame as its env.
 func (co *Compiler) VisitFunction(funcX *FunctionX) Value {
 	L("VisitFunction: FuncRecX = %#v", funcX.FuncRecX)
+	if co.Outer != nil {
+		panic("lambdas can only be defined at the first level of a func, not inside another lambda")
+	}
+	if co.Subject == nil || co.CurrentBlock == nil {
+		panic("lambdas can only be defined inside a func")
+	}
 	funcRec := funcX.FuncRecX.VisitFuncRecX(co)
 	L("VisitFunction: FuncRec = %#v", funcRec)
 	t := &FunctionTV{funcRec}
-	panic(2007)
-	return &CVal{c: "?1702?", t: t}
+
+	ser := Serial("lambda")
+	gd := &GDef{
+		name:   ser,
+		CName:  CName(co.Subject.CName, ser),
+		initx:  funcX,
+		typex:  funcX,
+		typeof: t,
+	}
+	funcRec.gdef = gd
+
+	lco := NewCompiler(co.CMod, gd)
+	lco.Outer = co
+	lco.CurrentBlock = co.CurrentBlock // to find enclosing variables.
+	coHack = lco
+	lco.EmitFunc(gd, false /*justDeclare*/)
+	coHack = co
+	co.lambdas = append(co.lambdas, lco.Buf.String())
+
+	z := co.DefineLocalTempC(ser, t, "")
+	co.P("%s.fn = (FuncPtr)%s; %s.env = &fr; // L2340", z.CName, gd.CName, z.CName)
+	return z
+}
+
+// IsDirectFunc tells whether v is a global function, to be called by name,
+// rather than a Func value.
+func IsDirectFunc(v Value) bool {
+	if gd, ok := v.(*GDef); ok {
+		_, ok := gd.initx.(*FunctionX)
+		return ok
+	}
+	return false
+}
+
+// FuncValue makes a Func value from a global func or a method value,
+// which otherwise can only be called directly.  Its fn is a static
+// func taking the env first, which is the bound receiver, if any.
+// Other values are returned unchanged.
+func (co *Compiler) FuncValue(v Value) Value {
+	var fn, env string
+	switch t := v.(type) {
+	case *GDef:
+		if !IsDirectFunc(t) {
+			return v
+		}
+		fn = CName(t.CName, Serial("value"))
+		co.thunks = append(co.thunks, ForwardingC(fn, t.typeof.(*FunctionTV).FuncRec, t.CName, ""))
+		env = "(void*)0"
+	case *BoundMethodVal:
+		rcvr := co.Reify(t.receiver)
+		switch rt := rcvr.Type().(type) {
+		case *InterfaceTV:
+			// The dispatched method already takes a void* receiver.
+			fn = co.RegisterDispatchReturnCaller(t, rcvr)
+			env = rcvr.ToC()
+		case *NamedTV:
+			// Bind a copy of the receiver, in a box.
+			box := co.DefineLocalTempC(Serial("box"), &PointerTV{rt}, F("(%s*)oalloc(sizeof(%s), CLASS_%s)", rt.CType(), rt.CType(), rt.cname))
+			co.P("*%s = %s;", box.CName, rcvr.ToC())
+			fn = CName(t.cmeth, Serial("value"))
+			co.thunks = append(co.thunks, ForwardingC(fn, t.mtype.(*FunctionTV).FuncRec, t.cmeth, F("*(%s*)receiver", rt.CType())))
+			env = box.CName
+		default:
+			fn = CName(t.cmeth, Serial("value"))
+			co.thunks = append(co.thunks, ForwardingC(fn, t.mtype.(*FunctionTV).FuncRec, t.cmeth, F("(%s)receiver", rt.CType())))
+			env = rcvr.ToC()
+		}
+	default:
+		return v
+	}
+	z := co.DefineLocalTempC(Serial("funcval"), v.Type(), "")
+	co.P("%s.fn = (FuncPtr)%s; %s.env = %s; // L2346", z.CName, fn, z.CName, env)
+	return z
 }
 
 var IDENTIFIER = regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
@@ -2333,6 +3494,7 @@ func (co *Compiler) Reify(x Value) Value {
 }
 func (co *Compiler) ReifyAs(x Value, as TypeValue) Value {
 	Jot("// PDQ ReifyAs(x, as): %v ; %v", x, as)
+	x = co.FuncValue(x)
 	L("ccc x %v", x)
 	L("ccc x.Type %v", x.Type())
 	L("ccc as %v", as)
@@ -2393,13 +3555,39 @@ func (co *Compiler) VisitMake(args []Expr) Value {
 			c: F("MakeSlice(%q, %s, %s, sizeof(%s))", t.E.TypeCode(), theLen, theCap, t.E.CType()),
 			t: tv,
 		}
+	case *MapTV:
+		// Entries start with a `next` handle, which is not marked by the shape,
+		// and a seq.
+		eshape := MarkShape(4, []TypeValue{t.K, t.V})
+		return &CVal{
+			c: F("MakeMap(%q, '%c', sizeof(%s), sizeof(%s))", eshape, MapKeyKind(t.K), t.K.CType(), t.V.CType()),
+			t: tv,
+		}
+	case *ChanTV:
+		// The len arg of make is the capacity of the buffer.
+		return &CVal{
+			c: F("MakeChan(%q, sizeof(%s), %s)", MarkShape(0, []TypeValue{t.E}), t.E.CType(), theLen),
+			t: tv,
+		}
 	}
 	panic(F("cannot `make` a %v", tv))
 }
+
+// MapKeyKind tells the runtime how to hash and compare keys.
+func MapKeyKind(key TypeValue) byte {
+	switch key.TypeCode()[0] {
+	case 's':
+		return 's'
+	case 'z', 'b', 'i', 'u', 'p', 'P', 'I':
+		return 'b'
+	}
+	panic(F("unsupported map key type: %v", key))
+}
+
 func (co *Compiler) VisitAppend(args []Expr, hasDotDotDot bool) Value {
 	slice := args[0].VisitExpr(co)
 	slicec := slice.ToC()
-	slice_t := slice.Type().(*SliceTV)
+	slice_t := Underlying(slice.Type()).(*SliceTV)
 
 	// TODO: move extra processing up to CallX somehow?
 	var items []Value
@@ -2418,7 +3606,7 @@ func (co *Compiler) VisitAppend(args []Expr, hasDotDotDot bool) Value {
 
 	for _, e := range items {
 		// TODO: avoid extra Reify.
-		r := co.ReifyAs(e, slice.Type().(*SliceTV).E)
+		r := co.ReifyAs(e, Underlying(slice.Type()).(*SliceTV).E)
 		co.P("%s = SliceAppend(%s, &%s, sizeof(%s), 1 /*TODO: base_cls L2174*/);", slicec, slicec, r.ToC(), r.Type().CType())
 	}
 
@@ -2438,7 +3626,7 @@ func (co *Compiler) VisitLen(args []Expr) Value {
 	assert(len(args) == 1)
 	a := args[0].VisitExpr(co)
 
-	switch t := a.Type().(type) {
+	switch t := Underlying(a.Type()).(type) {
 	case *PrimTV:
 		switch a.Type().TypeCode()[0] {
 		case 's': // string
@@ -2449,20 +3637,62 @@ func (co *Compiler) VisitLen(args []Expr) Value {
 		// TODO: avoid runtime division.
 		return &CVal{c: F("((%s).len / sizeof(%s))", a.ToC(), t.E.CType()), t: IntTO}
 
+	case *ArrayTV:
+		return TypedK(int64(t.N), IntTO)
+
 	case *MapTV:
-		panic(2194)
+		return &CVal{c: F("MapLen(%s)", a.ToC()), t: IntTO}
+
+	case *ChanTV:
+		// Receives in the same expression happen before the CVal is used,
+		// so take the len now.
+		return co.DefineLocalTempC(Serial("len"), IntTO, F("ChanLen(%s)", a.ToC()))
 	}
 	panic(2195)
 }
+func (co *Compiler) VisitDelete(args []Expr) {
+	assert(len(args) == 2)
+	m := args[0].VisitExpr(co)
+	mapT, ok := Underlying(m.Type()).(*MapTV)
+	if !ok {
+		panic(F("first arg to `delete` must be a map; got %v", m))
+	}
+	key := co.ReifyAs(args[1].VisitExpr(co), mapT.K)
+	co.P("MapDelete(%s, &%s); // L2207", m.ToC(), key.ToC())
+}
+func (co *Compiler) VisitClose(args []Expr) {
+	assert(len(args) == 1)
+	ch := args[0].VisitExpr(co)
+	if _, ok := Underlying(ch.Type()).(*ChanTV); !ok {
+		panic(F("arg to `close` must be a chan; got %v", ch))
+	}
+	co.P("ChanClose(%s); // L2217", ch.ToC())
+}
 func (co *Compiler) VisitPanic(args []Expr) {
 	assert(len(args) == 1)
 	val := args[0].VisitExpr(co)
-	s := fmt.Sprintf("%v", val)
-	s = fmt.Sprintf("%q", s)
-	s = s[1 : len(s)-1]
-	co.P(`fprintf(stderr, "\nPANIC: %s :: %s\n"); // L2197`, s, val.Type().CType())
-	co.P(`fprintf(stderr, "PANIC at line %%d file %%s\n", __LINE__, __FILE__);`)
-	co.P("exit(63); // L2199")
+	arg := co.DefineLocalTempV(Serial("panic"), AnyTO, val)
+	co.P("Panic(%s); // L2199", arg.CName)
+}
+
+// VisitValues evaluates a list of expressions, like call arguments
+// or return values.  A lone call with several results, as in
+// `f(g())` or `return g()`, is spread into its results.
+func (co *Compiler) VisitValues(xx []Expr) []Value {
+	var vals []Value
+	for _, x := range xx {
+		vals = append(vals, x.VisitExpr(co))
+	}
+	if len(vals) == 1 {
+		if mtv, ok := vals[0].Type().(*MultiTV); ok {
+			co.P("%s; // Call with multi spread: L2181", vals[0].ToC())
+			vals = nil
+			for _, r := range mtv.Multi {
+				vals = append(vals, &CVal{c: "tmp_" + r.name, t: r.TV})
+			}
+		}
+	}
+	return vals
 }
 
 func (co *Compiler) VisitCall(callx *CallX) Value {
@@ -2485,6 +3715,16 @@ func (co *Compiler) VisitCall(callx *CallX) Value {
 			assert(!callx.HasDotDotDot)
 			co.VisitPanic(callx.Args)
 			return &CVal{"/*void L2200*/", VoidTO}
+
+		case "delete":
+			assert(!callx.HasDotDotDot)
+			co.VisitDelete(callx.Args)
+			return &CVal{"/*void L2206*/", VoidTO}
+
+		case "close":
+			assert(!callx.HasDotDotDot)
+			co.VisitClose(callx.Args)
+			return &CVal{"/*void L2211*/", VoidTO}
 		}
 	}
 
@@ -2504,9 +3744,13 @@ func (co *Compiler) VisitCall(callx *CallX) Value {
 	funcVal := callx.Func.VisitExpr(co)
 	L("funcVal = %v", funcVal)
 	funcValType := funcVal.Type()
+	if bm, ok := funcVal.(*BoundMethodVal); ok {
+		// Called directly, a method takes its receiver first.
+		funcValType = bm.mtype
+	}
 	L("funcValType = %v", funcValType)
 
-	switch funcValType_t := funcValType.(type) {
+	switch funcValType_t := Underlying(funcValType).(type) {
 	case *PrimTV:
 		if funcValType_t.typecode == "t" {
 			// Casting to a type.
@@ -2558,12 +3802,15 @@ func (co *Compiler) VisitCall(callx *CallX) Value {
 					argVals = append(argVals, bmReceiver)
 					callme = bm.cmeth
 				}
-			} else {
+			} else if IsDirectFunc(funcVal) {
 				callme = funcVal.ToC()
+			} else {
+				// Call through a Func value, passing its env first.
+				fv := co.Reify(funcVal)
+				callme = F("((%s)(%s).fn)", co.CGen.FuncPtrType(funcRec), fv.ToC())
+				argc = append(argc, F("(%s).env", fv.ToC()))
 			}
-			for _, e := range callx.Args {
-				argVals = append(argVals, e.VisitExpr(co))
-			}
+			argVals = append(argVals, co.VisitValues(callx.Args)...)
 
 			co.P("// Func is V %#v", callme)
 			for i, a := range argVals {
@@ -2712,25 +3959,41 @@ func (co *Compiler) VisitSub(subx *SubX) Value {
 
 func (co *Compiler) VisitSubSlice(ssx *SubSliceX) Value {
 	ser := Serial("subslice")
-	con := ssx.container.VisitExpr(co)
+	var con Value
+	if dotx, ok := ssx.container.(*DotX); ok {
+		x := dotx.X.VisitExpr(co)
+		con = co.Member(x, dotx.Member)
+		if at, ok := Underlying(con.Type()).(*ArrayTV); ok {
+			return co.SliceArrayField(ssx, x, dotx.Member, at)
+		}
+	} else {
+		con = ssx.container.VisitExpr(co)
+	}
+	var elementCType string
+	switch t := Underlying(con.Type()).(type) {
+	case *SliceTV:
+		elementCType = t.E.CType()
+	case *ArrayTV:
+		panic(F("cannot slice array %v: only arrays in struct fields can be sliced", ssx.container))
+	default:
+		if t != StringTO {
+			panic(F("cannot slice %v (type %s)", ssx.container, TypeName(con.Type())))
+		}
+		// A substring shares the bytes of the string.
+		elementCType = ByteTO.CType()
+	}
+	con = co.Reify(con)
 	conc := con.ToC()
 	z := co.DefineLocalTempC(ser, con.Type(), "")
 	zc := z.ToC()
 
-	co.P("// DEBUG SLICE: con.Type() == %#v", con.Type())
-	co.P("// DEBUG *: con.Type().(SliceTV*) == %#v", con.Type().(*SliceTV))
-	co.P("// DEBUG E: con.Type().(SliceTV*).E == %#v", con.Type().(*SliceTV).E)
-	co.P("// DEBUG ToC: con.Type().(SliceTV*).E == %#v", con.Type().(*SliceTV).E.CType())
-	elementCType := con.Type().(*SliceTV).E.CType()
-
-	var a, b Value
 	var ac, bc string
 	if ssx.a != nil {
-		a = ssx.a.VisitExpr(co)
+		a := co.ReifyAs(ssx.a.VisitExpr(co), IntTO)
 		ac = fmt.Sprintf("(%s * sizeof(%s))", a.ToC(), elementCType)
 	}
 	if ssx.b != nil {
-		b = ssx.b.VisitExpr(co)
+		b := co.ReifyAs(ssx.b.VisitExpr(co), IntTO)
 		bc = fmt.Sprintf("(%s * sizeof(%s))", b.ToC(), elementCType)
 	}
 
@@ -2768,61 +4031,133 @@ func (co *Compiler) VisitSubSlice(ssx *SubSliceX) Value {
 	return z
 }
 
+// SliceArrayField slices the array in field of the struct
+// that handle x points to, like `p.buf[a:b]`.  The slice is
+// into the struct's GC Heap object.  Arrays elsewhere are not
+// in the heap, so they cannot be sliced.
+func (co *Compiler) SliceArrayField(ssx *SubSliceX, x Value, field string, at *ArrayTV) Value {
+	h := co.Reify(x)
+	a, b := KVal(0), KVal(int64(at.N))
+	if ssx.a != nil {
+		a = co.ReifyAs(ssx.a.VisitExpr(co), IntTO)
+	}
+	if ssx.b != nil {
+		b = co.ReifyAs(ssx.b.VisitExpr(co), IntTO)
+	}
+	co.P("CheckSlice(%s, %s, %d); // L3630", a.ToC(), b.ToC(), at.N)
+	z := co.DefineLocalTempC(Serial("subslice"), &SliceTV{at.E}, "")
+	size := F("sizeof(%s)", at.E.CType())
+	co.P("(%s).base = (word)(%s);", z.CName, h.ToC())
+	co.P("(%s).offset = (word)&(%s)->f_%s - (word)(%s) + (%s) * %s;",
+		z.CName, h.ToC(), field, h.ToC(), a.ToC(), size)
+	co.P("(%s).len = ((%s) - (%s)) * %s;", z.CName, b.ToC(), a.ToC(), size)
+	return z
+}
+
+func (co *Compiler) VisitArrayType(x *ArrayTX) Value {
+	n := x.N.VisitExpr(co)
+	k, ok := ConstInt(n)
+	if !ok || k < 0 {
+		panic(F("array length must be a non-negative constant: %v", x.N))
+	}
+	e := CompileTX(co, x.E, x).TV
+	return &TypeVal{co.CGen.ArrayType(int(k), e)}
+}
+
 func (co *Compiler) VisitTypeAssert(tass *TypeAssertX) Value {
-	x := tass.X.VisitExpr(co)
-	tx := x.Type()
-	xc := x.ToC()
-	castV := tass.T.VisitExpr(co)
-	castTV, ok := castV.ResolveAsTypeValue()
+	x, castTV := co.TypeAssertOperands(tass)
 
 	// Trivial assertion.
-	if tx.Equals(castTV) {
+	if x.typeof.Equals(castTV) {
 		return x
 	}
 
-	// Any to Concrete.
-	if tx.TypeCode() == "a" { // if x is an Any
-		co.P("assert(!strcmp(%q, (%s).typecode));",
-			castTV.TypeCode(), xc)
-
-		return &CVal{
-			c: F("(*(%s*)(%s).pointer)", castTV.CType(), xc),
-			t: castTV,
-		}
+	cond := co.HasDynamicTypeC(x, castTV)
+	if x.typeof == AnyTO {
+		co.P("if (!%s) PanicAssertAny(%s, %q); // L3090", cond, x.CName, TypeName(castTV))
+	} else {
+		co.P("if (!%s) PanicAssertHandle((word)(%s), %q); // L3092", cond, x.CName, TypeName(castTV))
 	}
+	g := co.DefineLocalTempC(Serial("assert"), castTV, "")
+	co.AssignDynamicType(x, g.CName, castTV)
+	return g
+}
 
-	// Interfaces and Pointers.
-	switch tx.(type) {
-	case *InterfaceTV, *PointerTV:
-		// ok
-	default:
-		panic(F("cannot runtime cast: %v", x))
+// TypeAssertCommaOk is like VisitTypeAssert, but yields a MultiTV
+// of the value and a bool, which is false (with a zero value)
+// if the interface does not hold that type.
+func (co *Compiler) TypeAssertCommaOk(tass *TypeAssertX) Value {
+	x, castTV := co.TypeAssertOperands(tass)
+	vName, okName := Serial("assert_v"), Serial("assert_ok")
+	v := co.DefineLocalTempC(vName, castTV, "")
+	okVar := co.DefineLocalTempC(okName, BoolTO, co.HasDynamicTypeC(x, castTV))
+	co.P("if (%s) {", okVar.CName)
+	co.AssignDynamicType(x, v.CName, castTV)
+	co.P("} else {")
+	co.P("memset(&%s, 0, sizeof %s); // L3110", v.CName, v.CName)
+	co.P("}")
+	return &CVal{
+		c: F("(%s)", okVar.CName),
+		t: &MultiTV{[]NameTV{{vName, castTV}, {okName, BoolTO}}},
 	}
+}
 
-	// Don't have runtime magic yet, so be liberal,
-	// allow any pointers and interfaces.
-
+// TypeAssertOperands evaluates the interface being asserted into
+// a temp, and resolves the type it is asserted to be.
+func (co *Compiler) TypeAssertOperands(tass *TypeAssertX) (*GDef, TypeValue) {
+	xv := tass.X.VisitExpr(co)
+	xt := xv.Type()
+	if _, ok := xt.(*InterfaceTV); !ok && xt != AnyTO {
+		panic(F("cannot type-assert non-interface %v", xv))
+	}
+	castV := tass.T.VisitExpr(co)
+	castTV, ok := castV.ResolveAsTypeValue()
 	if !ok {
 		panic(F("must type-assert to a type, not %v", castV))
 	}
-	switch castTV.(type) {
-	case *InterfaceTV, *PointerTV:
-	// ok
-	default:
-		panic(F("cannot runtime cast to type %v", castTV))
-	}
+	x := co.DefineLocalTempV(Serial("assert_x"), xt, xv)
+	return x, castTV
+}
 
-	ser := Serial("runtimeCast")
-	g := co.DefineLocalTempC(ser, castTV, "")
+// TypeName is the name of a type in runtime panic messages.
+func TypeName(tv TypeValue) string {
+	switch tv {
+	case AnyTO:
+		return "interface {}"
+	case ConstIntTO:
+		return "untyped int"
+	}
+	switch t := tv.(type) {
+	case *PrimTV:
+		return t.name
+	case *InterfaceTV:
+		return t.InterfaceRec.cname
+	case *NamedTV:
+		return t.cname
+	case *PointerTV:
+		if st, ok := t.E.(*StructTV); ok {
+			return "*" + st.StructRec.cname
+		}
+	}
+	return tv.TypeCode()
+}
 
-	co.P("%s = (void*)(%s); // L2402", g.ToC(), x.ToC())
-	return g
+// GoTypeName is the name of a type in compile errors,
+// with Go's `pkg.Name` for the C name `pkg__Name`.
+func GoTypeName(tv TypeValue) string {
+	return strings.Replace(TypeName(tv), "__", ".", -1)
 }
 
 func (co *Compiler) VisitDot(dotx *DotX) Value {
 	log.Printf("VisitDot: <------ %v", dotx)
 	val := dotx.X.VisitExpr(co)
 	log.Printf("VisitDot: val-- %T ---- %v", val, val)
+	return co.Member(val, dotx.Member)
+}
+
+// Member is the field or method named member of val,
+// or the member of an imported module.
+func (co *Compiler) Member(val Value, member string) Value {
 
 	switch t := val.Type().(type) {
 	case *PrimTV:
@@ -2833,11 +4168,11 @@ func (co *Compiler) VisitDot(dotx *DotX) Value {
 
 			if otherMod, ok := co.CGen.Mods[modName]; ok {
 				log.Printf("OM %#v", otherMod)
-				if x, ok := otherMod.Members[dotx.Member]; ok {
+				if x, ok := otherMod.Members[member]; ok {
 					L("member: %v", x)
-					return x
+					return ConstOrDef(x)
 				} else {
-					panic(F("member not found: %q", dotx.Member))
+					panic(F("member not found: %q", member))
 				}
 			}
 		}
@@ -2849,12 +4184,12 @@ func (co *Compiler) VisitDot(dotx *DotX) Value {
 			rec := faceType.InterfaceRec
 			Say("rec", rec)
 			Say("rec", F("%#v", rec))
-			if mtype, ok := FindTypeByName(rec.Meths, dotx.Member); ok {
+			if mtype, ok := FindTypeByName(rec.Meths, member); ok {
 				ftv := mtype.(*FunctionTV)
 				frec := ftv.FuncRec
 				bm := &BoundMethodVal{
 					receiver: val,
-					cmeth:    dotx.Member,
+					cmeth:    member,
 					mtype:    mtype,
 					// face already omits receiver from FuncRec
 					typecode: frec.BuildTypeCode(false),
@@ -2864,26 +4199,39 @@ func (co *Compiler) VisitDot(dotx *DotX) Value {
 				return bm
 			}
 		}
+	case *NamedTV:
+		if mtype, ok := FindTypeByName(t.Meths, member); ok {
+			ftv := mtype.(*FunctionTV)
+			bm := &BoundMethodVal{
+				receiver: val,
+				cmeth:    CName(t.cname, member),
+				mtype:    mtype,
+				typecode: ftv.FuncRec.BuildTypeCode(false),
+				isFace:   false,
+			}
+			L("VisitDot returns bound meth: %#v", bm)
+			return bm
+		}
 	case *PointerTV:
 		if structType, ok := t.E.(*StructTV); ok {
 			Say("structType", structType)
 			rec := structType.StructRec
 			Say("rec", rec)
 			Say("rec", F("%#v", rec))
-			if ftype, ok := FindTypeByName(rec.Fields, dotx.Member); ok {
+			if ftype, ok := FindTypeByName(rec.Fields, member); ok {
 				z := &CVal{
-					c: Format("(%s)->f_%s", val.ToC(), dotx.Member),
+					c: Format("(%s)->f_%s", val.ToC(), member),
 					t: ftype,
 				}
 				L("VisitDot returns Field: %#v", z)
 				return z
 			}
-			if mtype, ok := FindTypeByName(rec.Meths, dotx.Member); ok {
+			if mtype, ok := FindTypeByName(rec.Meths, member); ok {
 				ftv := mtype.(*FunctionTV)
 				frec := ftv.FuncRec
 				bm := &BoundMethodVal{
 					receiver: val,
-					cmeth:    CName(rec.cname, dotx.Member),
+					cmeth:    CName(rec.cname, member),
 					mtype:    mtype,
 					typecode: frec.BuildTypeCode(false),
 					isFace:   false,
@@ -2904,7 +4252,7 @@ func (co *Compiler) VisitVar(v *VarStmt) {
 func (co *Compiler) AssignSingle(left Value, right Value) {
 	switch lt := left.(type) {
 	case *SubVal:
-		switch lt.container.Type().(type) {
+		switch Underlying(lt.container.Type()).(type) {
 		case *SliceTV:
 			// slice, size, nth, value
 			nth, ok := ResolveAsIntStr(lt.subscript)
@@ -2915,16 +4263,42 @@ func (co *Compiler) AssignSingle(left Value, right Value) {
 
 			co.P(" SlicePut(%s, sizeof(%s), %s, &%s); // L2071",
 				lt.container.ToC(),
-				right.Type().CType(),
+				rright.Type().CType(),
 				nth,
 				rright.ToC())
 			return
 
+		case *ArrayTV:
+			co.AssignSingle(&CVal{lt.ToC(), lt.Type()}, right)
+			return
+
 		case *MapTV:
-			panic(F("TODO MapTV L2070 (%v :: %v) = %v", left, lt, right))
+			mapT := Underlying(lt.container.Type()).(*MapTV)
+			rkey := co.ReifyAs(lt.subscript, mapT.K)
+			rright := co.ReifyAs(right, mapT.V)
+			co.P(" MapPut(%s, &%s, &%s); // L2070",
+				lt.container.ToC(),
+				rkey.ToC(),
+				rright.ToC())
+			return
 		}
 		panic(F("todo SubVal L1835: (%v :: %v) = %v", left, lt, right))
 	default:
+		leftT := left.Type()
+		if _, ok := leftT.(*InterfaceTV); (ok || leftT == AnyTO) && !right.Type().Equals(leftT) {
+			// Boxing into an interface.
+			co.ConvertToCNameType(right, left.ToC(), leftT)
+			return
+		}
+		if _, ok := Underlying(leftT).(*FunctionTV); ok {
+			// From a global func, a method value, or nil.
+			co.ConvertToCNameType(right, left.ToC(), leftT)
+			return
+		}
+		CheckConstFits(right, leftT)
+		if _, ok := ConstInt(right); ok && right.Type() != ConstIntTO && !right.Type().Equals(leftT) {
+			panic(F("cannot use constant %s of type %s as %s in assignment", right.ToC(), TypeName(right.Type()), TypeName(leftT)))
+		}
 		co.P("%s = %s; // L2447", left.ToC(), right.ToC())
 		return
 	}
@@ -2932,6 +4306,10 @@ func (co *Compiler) AssignSingle(left Value, right Value) {
 }
 func (co *Compiler) VisitAssign(ass *AssignS) {
 	L("//## assign..... %v   %v   %v", ass.A, ass.Op, ass.B)
+	if IsCompoundAssignOp(ass.Op) {
+		co.AssignOp(ass)
+		return
+	}
 	lenA, lenB := len(ass.A), len(ass.B)
 	_ = lenA
 	_ = lenB // TODO
@@ -2939,8 +4317,22 @@ func (co *Compiler) VisitAssign(ass *AssignS) {
 	// Evalute the rvalues.
 	var rvalues []Value
 	for _, e := range ass.B {
+		if recv, ok := e.(*RecvX); ok && len(ass.A) == 2 && len(ass.B) == 1 {
+			// `v, ok := <-ch` must not receive until we know it is comma-ok.
+			rvalues = append(rvalues, co.RecvCommaOk(recv))
+			continue
+		}
+		if tass, ok := e.(*TypeAssertX); ok && len(ass.A) == 2 && len(ass.B) == 1 {
+			// `v, ok := x.(T)` must not panic if x does not hold a T.
+			rvalues = append(rvalues, co.TypeAssertCommaOk(tass))
+			continue
+		}
 		rvalues = append(rvalues, e.VisitExpr(co))
 	}
+	// A comma-ok form, like `v, ok := m[k]`, yields two results.
+	if len(ass.A) == 2 && len(rvalues) == 1 {
+		rvalues[0] = co.CommaOk(rvalues[0])
+	}
 
 	// Create local vars, if `:=` is the op.
 	var newLocals []*GDef
@@ -3001,12 +4393,15 @@ func (co *Compiler) VisitAssign(ass *AssignS) {
 		callVal := rvalues[0]
 		co.P("%s; // Call with no assign: L2001", callVal.ToC())
 
+	case ass.A == nil && len(ass.B) == 1 && IsRecv(ass.B[0]):
+		// CASE: No assignment.  VisitRecv already received.
+
 	case ass.A == nil && bcall == nil:
 		// CASE: No assignment.  Just a non-function not allowed.
 		panic(Format("L2005: Lone expr is not a function call: [%v]", ass.B))
 
-	case len(ass.A) > 1 && bcall != nil:
-		// CASE From 1 call, to 2 or more assigned vars.
+	case len(ass.A) > 1 && len(ass.B) == 1:
+		// CASE From 1 call (or comma-ok), to 2 or more assigned vars.
 		callVal := rvalues[0]
 		mtv, ok := callVal.Type().(*MultiTV)
 		if !ok {
@@ -3017,14 +4412,22 @@ func (co *Compiler) VisitAssign(ass *AssignS) {
 		}
 
 		co.P("%s; // Call with multi assign: L2009", callVal.ToC())
+		var targets []Value
 		for i, dest := range ass.A {
-			destVal := dest.VisitExpr(co)
-			// TODO Sub
-			// TODO type of destVal
-			// TODO: Why am I adding tmp_ to this multi result?
-			co.P("%s = tmp_%s; // Multi result [%d].  L2020", destVal.ToC(), mtv.Multi[i].name, i)
+			if newLocals != nil {
+				targets = append(targets, newLocals[i])
+			} else {
+				targets = append(targets, co.LValue(dest))
+			}
 		}
-
+		for i, target := range targets {
+			if target == nil {
+				continue
+			}
+			result := &CVal{c: "tmp_" + mtv.Multi[i].name, t: mtv.Multi[i].TV}
+			co.AssignSingle(target, result) // Multi result [i].  L2020
+		}
+
 	case len(ass.A) == 1 && len(ass.B) == 1:
 		// CASE: Simple 1,1
 		var target Value
@@ -3040,32 +4443,297 @@ func (co *Compiler) VisitAssign(ass *AssignS) {
 		if len(ass.A) != len(ass.B) {
 			Panicf("wrong number of values in assign: left has %d, right has %d", len(ass.A), len(ass.B))
 		}
-		for i, val := range rvalues {
-			lhs := ass.A[i]
-			switch t := lhs.(type) {
-			case *IdentX:
-				// TODO -- check that variable t.X has the right type.
-				switch ass.Op {
-				case "=":
-					// TODO check Globals
-					cvar := "v_" + t.X
-					L("cvar = %v", cvar)
-					L("val = %v", val)
-					L("val.Type() = %v", val.Type())
-					L("val.Type().CType() = %v", val.Type().CType())
-					L("val.ToC() = %v", val.ToC())
-					co.P("  %s = (%s)(%s);", cvar, val.Type().CType(), val.ToC())
-				case ":=":
-					// TODO check Globals
-					cvar := Format("%s %s", val.Type().CType(), "v_"+t.X)
-					co.P("  %s = (%s)(%s);", cvar, val.Type().CType(), val.ToC())
-				}
-			default:
-				log.Fatalf("bad VisitAssign LHS: %#v", ass.A)
+		if newLocals != nil {
+			// New locals cannot appear on the right, so assign directly.
+			for i, val := range rvalues {
+				co.AssignSingle(newLocals[i], val)
+			}
+			return
+		}
+		// As in Go, finish evaluating both sides before assigning any,
+		// so that `a, b = b, a` swaps.
+		var targets []Value
+		for _, lhs := range ass.A {
+			targets = append(targets, co.LValue(lhs))
+		}
+		var temps []Value
+		for _, val := range rvalues {
+			if IsConst(val) {
+				temps = append(temps, val)
+			} else {
+				temps = append(temps, co.DefineLocalTempC(Serial("assign"), val.Type(), val.ToC()))
+			}
+		}
+		for i, target := range targets {
+			if target != nil {
+				co.AssignSingle(target, temps[i])
 			}
 		}
 	} // switch
 }
+
+// LValue evaluates the target of an assignment, returning nil for `_`.
+// The pointer to a field's struct, and subscripts and map or slice
+// containers, are copied into temps now, so that other assignments
+// in the same statement cannot change them.
+func (co *Compiler) LValue(x Expr) Value {
+	switch t := x.(type) {
+	case *IdentX:
+		if t.X == "_" {
+			return nil
+		}
+	case *DotX:
+		// Copy even plain variables, which may be assigned too,
+		// as in `p, p.left = q, 100`.
+		recv := t.X.VisitExpr(co)
+		if _, ok := Underlying(recv.Type()).(*PointerTV); ok {
+			recv = co.DefineLocalTempC(Serial("lvalue"), recv.Type(), recv.ToC())
+		}
+		return co.Member(recv, t.Member)
+	case *SubX:
+		// An array must be assigned in place,
+		// but the struct holding it is copied as above.
+		container := co.LValue(t.container)
+		if _, isArray := Underlying(container.Type()).(*ArrayTV); !isArray {
+			container = co.DefineLocalTempC(Serial("lvalue"), container.Type(), container.ToC())
+		}
+		subscript := t.subscript.VisitExpr(co)
+		if !IsConst(subscript) {
+			subscript = co.DefineLocalTempC(Serial("lvalue"), subscript.Type(), subscript.ToC())
+		}
+		return &SubVal{
+			container: container,
+			subscript: subscript,
+		}
+	}
+	return x.VisitExpr(co)
+}
+
+// AssignOp does an assignment like `x += y`.
+// The parts of the target are evaluated only once.
+func (co *Compiler) AssignOp(ass *AssignS) {
+	if len(ass.A) != 1 || len(ass.B) != 1 {
+		panic(F("operator %s requires one value on each side; got %v and %v", ass.Op, ass.A, ass.B))
+	}
+	target := ass.A[0].VisitExpr(co)
+	if sub, ok := target.(*SubVal); ok {
+		target = &SubVal{
+			container: co.Reify(sub.container),
+			subscript: co.Reify(sub.subscript),
+		}
+	}
+	b := ass.B[0].VisitExpr(co)
+	op := strings.TrimSuffix(ass.Op, "=")
+	co.AssignSingle(target, co.BinOp(target, op, b))
+}
+
+// CommaOk converts a value that has a comma-ok form
+// (like a map index) into a MultiTV of the value and a bool.
+// Other values are returned unchanged.
+func (co *Compiler) CommaOk(x Value) Value {
+	if sub, ok := x.(*SubVal); ok {
+		if mapT, ok := Underlying(sub.container.Type()).(*MapTV); ok {
+			key := co.ReifyAs(sub.subscript, mapT.K)
+			vName, okName := Serial("mapget_v"), Serial("mapget_ok")
+			v := co.DefineLocalTempC(vName, mapT.V, "")
+			okVar := co.DefineLocalTempC(okName, BoolTO, "")
+			return &CVal{
+				c: F("(%s = MapGet(%s, &%s, &%s, sizeof(%s)))",
+					okVar.CName, sub.container.ToC(), key.ToC(), v.CName, mapT.V.CType()),
+				t: &MultiTV{[]NameTV{{vName, mapT.V}, {okName, BoolTO}}},
+			}
+		}
+	}
+	return x
+}
+
+func IsRecv(x Expr) bool {
+	_, ok := x.(*RecvX)
+	return ok
+}
+
+// VisitRecv receives into a temp, which is the value.
+func (co *Compiler) VisitRecv(recv *RecvX) Value {
+	ch := recv.Chan.VisitExpr(co)
+	chanT, ok := Underlying(ch.Type()).(*ChanTV)
+	if !ok {
+		panic(F("cannot receive from non-chan %v", ch))
+	}
+	v := co.DefineLocalTempC(Serial("recv"), chanT.E, "")
+	co.P("ChanRecv(%s, &%s); // L3240", ch.ToC(), v.CName)
+	return v
+}
+
+// RecvCommaOk is like VisitRecv, but yields a MultiTV
+// of the value and a bool, which is false if the chan is closed.
+func (co *Compiler) RecvCommaOk(recv *RecvX) Value {
+	ch := recv.Chan.VisitExpr(co)
+	chanT, ok := Underlying(ch.Type()).(*ChanTV)
+	if !ok {
+		panic(F("cannot receive from non-chan %v", ch))
+	}
+	vName, okName := Serial("recv_v"), Serial("recv_ok")
+	v := co.DefineLocalTempC(vName, chanT.E, "")
+	okVar := co.DefineLocalTempC(okName, BoolTO, "")
+	return &CVal{
+		c: F("(%s = ChanRecv(%s, &%s))", okVar.CName, ch.ToC(), v.CName),
+		t: &MultiTV{[]NameTV{{vName, chanT.E}, {okName, BoolTO}}},
+	}
+}
+
+func (co *Compiler) VisitSend(send *SendS) {
+	ch := send.Chan.VisitExpr(co)
+	chanT, ok := Underlying(ch.Type()).(*ChanTV)
+	if !ok {
+		panic(F("cannot send to non-chan %v", ch))
+	}
+	val := co.DefineLocalTempV(Serial("send"), chanT.E, send.Value.VisitExpr(co))
+	co.P("ChanSend(%s, &%s); // L3266", ch.ToC(), val.CName)
+}
+
+// VisitGo evaluates the function and arguments now, into temps,
+// and copies them into a struct that goes with the new goroutine.
+// A thunk, emitted before this func, unpacks the struct and
+// makes the call.
+func (co *Compiler) VisitGo(g *GoS) {
+	callx := g.Call
+	if _, ok := callx.Func.(*FunctionX); ok {
+		// Its env is our frame, which may be gone before it runs.
+		panic("cannot use a lambda in `go`; use a global func or method")
+	}
+	funcVal := callx.Func.VisitExpr(co)
+	ftv, ok := Underlying(funcVal.Type()).(*FunctionTV)
+	if !ok {
+		panic(F("cannot use as a function in `go`: %v", funcVal))
+	}
+	rec := ftv.FuncRec
+	if rec.HasDotDotDot {
+		panic(F("cannot `go` a func with `...` args: %v", funcVal))
+	}
+	ser := Serial("go")
+
+	// Fields of the struct, and how the thunk calls the func with them.
+	var fields []NameTV
+	var vals []Value
+	var callme string
+	var argc []string
+	fins := rec.Ins
+	if bm, _ := funcVal.(*BoundMethodVal); bm != nil {
+		fields = append(fields, NameTV{"rcvr", bm.receiver.Type()})
+		vals = append(vals, bm.receiver)
+		argc = append(argc, "a->rcvr")
+		if bm.isFace {
+			callme = co.RegisterDispatchReturnCaller(bm, &CVal{c: "a->rcvr", t: bm.receiver.Type()})
+		} else {
+			callme = bm.cmeth
+		}
+	} else if IsDirectFunc(funcVal) {
+		callme = funcVal.ToC()
+	} else {
+		fields = append(fields, NameTV{"fn", ftv})
+		vals = append(vals, funcVal)
+		callme = F("((%s)(a->fn).fn)", co.CGen.FuncPtrType(rec))
+		argc = append(argc, "(a->fn).env")
+	}
+	if len(fins) != len(callx.Args) {
+		panic(F("got %d args for func call, wanted %d args", len(callx.Args), len(fins)))
+	}
+	for i, in := range fins {
+		name := F("in_%d", i)
+		fields = append(fields, NameTV{name, in.TV})
+		vals = append(vals, callx.Args[i].VisitExpr(co))
+		argc = append(argc, "a->"+name)
+	}
+	// Multiple results are stored in the struct, and ignored.
+	if len(rec.Outs) > 1 {
+		for i, out := range rec.Outs {
+			name := F("out_%d", i)
+			fields = append(fields, NameTV{name, out.TV})
+			argc = append(argc, "&a->"+name)
+		}
+	}
+
+	var temps []*GDef
+	for i, val := range vals {
+		temps = append(temps, co.DefineLocalTempV(CName(ser, fields[i].name), fields[i].TV, val))
+	}
+
+	argsT, thunk := "GoArgs_"+ser, "GoThunk_"+ser
+	var code []string
+	var types []TypeValue
+	if len(fields) > 0 {
+		code = append(code, F("struct %s {", argsT))
+		for _, f := range fields {
+			code = append(code, F("  %s %s;", f.TV.CType(), f.name))
+			types = append(types, f.TV)
+		}
+		code = append(code, "};")
+	}
+	code = append(code, F("static void %s(void* p) {", thunk))
+	if len(fields) > 0 {
+		code = append(code, F("  struct %s* a = (struct %s*)p;", argsT, argsT))
+	}
+	code = append(code, F("  %s;", co.FormatCall(callme, argc)))
+	code = append(code, "}")
+	co.thunks = append(co.thunks, strings.Join(code, "\n"))
+
+	if len(fields) == 0 {
+		co.P("Go(%s, NULL, 0, NULL); // L3340", thunk)
+		return
+	}
+	co.P("{ struct %s a; // L3343", argsT)
+	co.P("memset(&a, 0, sizeof a);")
+	for i, temp := range temps {
+		co.P("a.%s = %s;", fields[i].name, temp.CName)
+	}
+	co.P("Go(%s, &a, sizeof a, %q); }", thunk, MarkShape(0, types))
+}
+
+// VisitDefer evaluates the function and arguments now, into temps
+// in the frame, and saves the call to run when the func returns
+// or when a panic unwinds through it.
+// Since defer is only at the first level of a func, the Defers
+// are reached in order, so at runtime we only need to count them.
+// The first one installs a Catcher for panics.
+func (co *Compiler) VisitDefer(d *DeferS) {
+	if co.CurrentBlock.parent != co.funcBlock {
+		panic("`defer` can only be used at the first level of a func")
+	}
+	if id, ok := d.Call.Func.(*IdentX); ok {
+		switch id.X {
+		case "make", "append", "len", "panic", "delete":
+			panic(F("cannot defer builtin %q", id.X))
+		}
+	}
+	if len(co.Defers) == 0 {
+		co.deferCount = co.DefineLocalTempC("defers", ByteTO, "")
+		outs := co.Subject.Type().(*FunctionTV).FuncRec.Outs
+		if len(outs) == 1 && co.namedResults == nil {
+			co.zeroResult = co.DefineLocalTempC("zero_result", outs[0].TV, "")
+		}
+		co.P("catcher.prev = CurrentCatcher; CurrentCatcher = &catcher; catcher.deferring = DeferringFrame;")
+		co.P("if (setjmp(catcher.jb)) goto Panicked; // L2536")
+	}
+	call := d.Call.VisitExpr(co)
+	co.Defers = append(co.Defers, &DeferRec{ToDo: call.ToC()})
+	co.P("%s = %d; // L2540: defer #%d", co.deferCount.CName, len(co.Defers), len(co.Defers))
+}
+
+// EmitRunDefers runs the Defers that were reached, in reverse order,
+// and uninstalls the Catcher.
+// Each is uncounted before it runs, in case it panics.
+func (co *Compiler) EmitRunDefers() {
+	if len(co.Defers) == 0 {
+		return
+	}
+	count := co.deferCount.CName
+	for i := len(co.Defers); i > 0; i-- {
+		co.P("if (%s >= %d) { %s = %d; DeferringFrame = (struct Frame*) &fr; %s; } // L2552: run defer #%d",
+			count, i, count, i-1, co.Defers[i-1].ToDo, i)
+	}
+	co.P("CurrentCatcher = catcher.prev; DeferringFrame = catcher.deferring;")
+}
+
 func (co *Compiler) VisitReturn(ret *ReturnS) {
 	log.Printf("return..... %v", ret.X)
 	log.Printf("co.Subject = %v", co.Subject)
@@ -3075,31 +4743,71 @@ func (co *Compiler) VisitReturn(ret *ReturnS) {
 	log.Printf("outs = %v", outs)
 	log.Printf("co.results = %v", co.results)
 
-	switch len(ret.X) {
+	vals := co.VisitValues(ret.X)
+	if co.namedResults != nil {
+		if len(vals) > 0 {
+			if len(outs) != len(vals) {
+				panic(F("L2523: Got %d return values, but needs %d", len(vals), len(outs)))
+			}
+			// Convert all the values before assigning any,
+			// in case they use the results, as in `return b, a`.
+			var temps []*GDef
+			for i, vx := range vals {
+				temps = append(temps, co.DefineLocalTempV(Serial("ret"), outs[i].TV, vx))
+			}
+			for i, temp := range temps {
+				co.AssignSingle(co.namedResults[i], temp)
+			}
+		}
+		// Deferred funcs may change the named results.
+		co.EmitRunDefers()
+		co.EmitReturnNamedResults()
+		return
+	}
+
+	switch len(vals) {
 	case 0:
+		if len(outs) != 0 {
+			panic(F("L2519: Got 0 return values, but needs %d", len(outs)))
+		}
+		co.EmitRunDefers()
 		co.P("  Where(); RETURN_NOTHING;")
 	case 1:
 		if len(outs) != 1 {
 			panic(F("L2516: Got 1 return value, but needs %d", len(outs)))
 		}
-		val := ret.X[0].VisitExpr(co)
-		reval := co.Reify(val)
+		// Copy the result before deferred funcs can change
+		// the variables it was made from.
+		reval := co.DefineLocalTempV(Serial("ret"), outs[0].TV, vals[0])
 		log.Printf("return..... reval=%v", reval)
-		co.P("  Where(); RETURN %s;", reval.ToC())
+		co.EmitRunDefers()
+		co.P("  Where(); RETURN %s;", reval.CName)
 	default:
-		if len(outs) != len(ret.X) {
-			panic(F("L2523: Got %d return values, but needs %d", len(ret.X), len(outs)))
+		if len(outs) != len(vals) {
+			panic(F("L2523: Got %d return values, but needs %d", len(vals), len(outs)))
 		}
-		for i, rx := range ret.X {
-			// TODO convert
-			vx := rx.VisitExpr(co)
-			//< co.P("  *%s = %s; // L2527: return multi #%d", co.results[i].name, vx.ToC(), i)
+		for i, vx := range vals {
 			r := co.results[i]
-			co.ConvertToCNameType(vx, "*"+r.name, r.TV)
+			co.ConvertToCNameType(vx, F("(*%s)", r.name), r.TV)
 		}
+		co.EmitRunDefers()
 		co.P("  Where(); RETURN_NOTHING; // L2529: multi")
 	}
 }
+
+// EmitReturnNamedResults returns the current values
+// of the named result variables.
+func (co *Compiler) EmitReturnNamedResults() {
+	if len(co.namedResults) == 1 {
+		co.P("  Where(); RETURN %s;", co.namedResults[0].CName)
+		return
+	}
+	for i, r := range co.results {
+		co.P("  *%s = %s; // L2531: named result", r.name, co.namedResults[i].CName)
+	}
+	co.P("  Where(); RETURN_NOTHING; // L2529: multi")
+}
+
 func (co *Compiler) VisitFor(fors *ForS) {
 	// FOR NOW, assume slice of byte.  TODO: string, map.
 	label := Serial("for")
@@ -3107,7 +4815,7 @@ func (co *Compiler) VisitFor(fors *ForS) {
 
 	collV := fors.Coll.VisitExpr(co)
 
-	switch coll_t := collV.Type().(type) {
+	switch coll_t := Underlying(collV.Type()).(type) {
 	case *SliceTV:
 		{
 			slice := co.Reify(collV)
@@ -3149,6 +4857,39 @@ func (co *Compiler) VisitFor(fors *ForS) {
 				co.P("SliceGet(%s, sizeof(%s), %s, &%s); //L2645", slice.ToC(), coll_t.E.CType(), index.CName, value.CName)
 			}
 		}
+	case *ArrayTV:
+		{
+			// Like Go, range over a copy of the array.
+			array := co.DefineLocalTempC(Serial("array"), collV.Type(), collV.ToC())
+			index := co.DefineLocalTempC("index_"+label, IntTO, "-1")
+			var key *GDef
+			switch k := fors.Key.(type) {
+			case (*IdentX):
+				if k.X != "_" && k.X != "" {
+					key = co.DefineLocal("v", k.X, IntTO)
+				}
+			}
+
+			var value *GDef
+			switch v := fors.Value.(type) {
+			case (*IdentX):
+				if v.X != "_" && v.X != "" {
+					value = co.DefineLocal("v", v.X, coll_t.E)
+				}
+			}
+
+			co.P("while(1) { Cont_%s: {}", label)
+
+			co.P("%s++; // L2690", index.CName)
+			co.P("if (%s >= %d) break; // L2691", index.CName, coll_t.N)
+
+			if key != nil {
+				co.P("%s = %s; // L2694:key", key.CName, index.CName)
+			}
+			if value != nil {
+				co.P("%s = (%s).a[%s]; // L2697", value.CName, array.ToC(), index.CName)
+			}
+		}
 	case *PrimTV:
 		if coll_t.typecode == "s" {
 			str := co.Reify(collV)
@@ -3190,6 +4931,54 @@ func (co *Compiler) VisitFor(fors *ForS) {
 				co.P("StringGet(%s, %s, &%s); //L2645", str.ToC(), index.CName, value.CName)
 			}
 		}
+	case *MapTV:
+		{
+			m := co.Reify(collV)
+			bucket := co.DefineLocalTempC("bucket_"+label, UintTO, "0")
+			seq := co.DefineLocalTempC("seq_"+label, UintTO, "0")
+			keyC, valueC := "NULL", "NULL"
+			switch k := fors.Key.(type) {
+			case nil:
+				{
+				}
+			case (*IdentX):
+				if k.X != "_" && k.X != "" {
+					key := co.DefineLocal("v", k.X, coll_t.K)
+					keyC = "&" + key.CName
+				}
+			}
+			switch v := fors.Value.(type) {
+			case nil:
+				{
+				}
+			case (*IdentX):
+				if v.X != "_" && v.X != "" {
+					value := co.DefineLocal("v", v.X, coll_t.V)
+					valueC = "&" + value.CName
+				}
+			}
+
+			co.P("while(1) { Cont_%s: {}", label)
+			co.P("if (!MapNext(%s, &%s, &%s, %s, %s)) break; // L2700", m.ToC(), bucket.CName, seq.CName, keyC, valueC)
+		}
+	case *ChanTV:
+		{
+			if fors.Value != nil {
+				panic("range over chan permits only one iteration variable")
+			}
+			ch := co.Reify(collV)
+			var elem *GDef
+			if k, ok := fors.Key.(*IdentX); ok && k.X != "_" && k.X != "" {
+				elem = co.DefineLocal("v", k.X, coll_t.E)
+			} else {
+				elem = co.DefineLocalTempC("elem_"+label, coll_t.E, "")
+			}
+
+			co.P("while(1) { Cont_%s: {}", label)
+			co.P("if (!ChanRecv(%s, &%s)) break; // L2722", ch.ToC(), elem.CName)
+		}
+	default:
+		panic(F("cannot range over %v", collV))
 	} // end switch collV type
 
 	savedB, savedC := co.BreakTo, co.ContinueTo
@@ -3252,28 +5041,350 @@ func (co *Compiler) VisitIf(ifs *IfS) {
 	co.P("  }}")
 	co.FinishScope()
 }
+
+// VisitSwitch tests the cases in order, jumping to the body
+// of the first that matches, or else to the default.
+// A body ends by jumping to the end, unless it falls through
+// to the next body.  A tagless switch tests bool cases.
 func (co *Compiler) VisitSwitch(sws *SwitchS) {
 	co.StartScope("VisitSwitch")
-	co.P("  { int _switch_ = %s;", sws.Switch.VisitExpr(co).ToC())
-	for _, c := range sws.Cases {
+	label := Serial("switch")
+	if sws.Init != nil {
+		sws.Init.VisitStmt(co)
+	}
+	var tag *GDef
+	if sws.Switch != nil {
+		x := sws.Switch.VisitExpr(co)
+		xt := x.Type()
+		if xt == ConstIntTO {
+			xt = IntTO
+		}
+		tag = co.DefineLocalTempV(label, xt, x)
+	}
+
+	caseLabel := func(i int) string { return F("Case_%s_%d", label, i) }
+	endLabel := "Break_" + label
+	defaultLabel := endLabel
+	for i, c := range sws.Cases {
+		if c.Matches == nil {
+			defaultLabel = caseLabel(i)
+			continue
+		}
+		for _, m := range c.Matches {
+			co.P("  if (%s) goto %s;", co.CaseMatchC(tag, m.VisitExpr(co)), caseLabel(i))
+		}
+	}
+	co.P("  goto %s;", defaultLabel)
+
+	savedB, savedF := co.BreakTo, co.FallthroughTo
+	co.BreakTo = endLabel
+	for i, c := range sws.Cases {
+		co.FallthroughTo = ""
+		for j, stmt := range c.Body.stmts {
+			if _, ok := stmt.(*FallthroughS); ok {
+				if j != len(c.Body.stmts)-1 {
+					panic("fallthrough statement out of place")
+				}
+				if i == len(sws.Cases)-1 {
+					panic("cannot fallthrough final case in switch")
+				}
+				co.FallthroughTo = caseLabel(i + 1)
+			}
+		}
 		co.StartScope("VisitCase")
-		co.P("  if (")
+		co.P("%s: {", caseLabel(i))
+		c.Body.VisitStmt(co)
+		co.P("  }")
+		co.P("  goto %s;", endLabel)
+		co.FinishScope()
+	}
+	co.BreakTo, co.FallthroughTo = savedB, savedF
+	co.P("%s: ;", endLabel)
+	co.FinishScope()
+}
+
+// CaseMatchC is C for whether the switch tag matches the case value m.
+// With no tag, m is the condition.  An interface tag matches
+// a value of another type if it holds an equal value of that type.
+func (co *Compiler) CaseMatchC(tag *GDef, m Value) string {
+	if tag == nil {
+		if m.Type() != BoolTO {
+			panic(F("case in switch without a tag must be bool; got %v", m))
+		}
+		return m.ToC()
+	}
+	_, isFace := tag.typeof.(*InterfaceTV)
+	mt := m.Type()
+	if (isFace || tag.typeof == AnyTO) && mt != NilTO && !mt.Equals(tag.typeof) {
+		if _, ok := mt.(*InterfaceTV); !ok && mt != AnyTO {
+			if mt == ConstIntTO {
+				mt = IntTO
+			}
+			rm := co.ReifyAs(m, mt)
+			held := co.DefineLocalTempC(Serial("held"), mt, "")
+			matched := co.DefineLocalTempC(Serial("matched"), BoolTO, "0")
+			co.P("  if (%s) {", co.HasDynamicTypeC(tag, mt))
+			co.AssignDynamicType(tag, held.CName, mt)
+			co.P("  %s = %s;", matched.CName, co.BinOp(held, "==", rm).ToC())
+			co.P("  }")
+			return matched.CName
+		}
+	}
+	return co.BinOp(tag, "==", m).ToC()
+}
+
+// VisitFallthrough jumps to the body of the next case.
+// VisitSwitch sets FallthroughTo if it is allowed.
+func (co *Compiler) VisitFallthrough(ft *FallthroughS) {
+	if co.FallthroughTo == "" {
+		panic("fallthrough statement out of place")
+	}
+	co.P("goto %s; // fallthrough", co.FallthroughTo)
+}
+
+// VisitTypeSwitch tests the dynamic type of the subject against
+// each case in order.  An interface{} holds a typecode string,
+// but all struct pointers have typecode "PR0", so they (and
+// non-empty interfaces, which are handles) are told apart by
+// their heap class, as EmitDispatch does.
+func (co *Compiler) VisitTypeSwitch(ts *TypeSwitchS) {
+	co.StartScope("VisitTypeSwitch")
+	if ts.Init != nil {
+		ts.Init.VisitStmt(co)
+	}
+	xv := ts.X.VisitExpr(co)
+	xt := xv.Type()
+	if _, ok := xt.(*InterfaceTV); !ok && xt != AnyTO {
+		panic(F("cannot type switch on non-interface %v", xv))
+	}
+	label := Serial("typeswitch")
+	x := co.DefineLocalTempV(label, xt, xv)
+	savedB := co.BreakTo
+	co.BreakTo = "Break_" + label
+
+	co.P("  {")
+	for _, c := range ts.Cases {
+		co.StartScope("VisitTypeCase")
+		var single TypeValue // The type, if just one type (not nil) matches.
+		var conds []string
 		for _, m := range c.Matches {
-			co.P("_switch_ == %s ||", m.VisitExpr(co).ToC())
+			mv := m.VisitExpr(co)
+			if mv.Type() == NilTO {
+				conds = append(conds, co.IsNilC(x))
+				continue
+			}
+			tv, ok := mv.ResolveAsTypeValue()
+			if !ok {
+				panic(F("type switch case must be a type, not %v", mv))
+			}
+			conds = append(conds, co.HasDynamicTypeC(x, tv))
+			if len(c.Matches) == 1 {
+				single = tv
+			}
+		}
+		co.P("  if (%s) {", strings.Join(conds, " || "))
+		if ts.Var != "" {
+			// Each case has its own variable, perhaps of a different type,
+			// so each needs its own slot in the frame.
+			if single != nil {
+				v := co.DefineLocal(Serial("v"), ts.Var, single)
+				co.AssignDynamicType(x, v.CName, single)
+			} else {
+				v := co.DefineLocal(Serial("v"), ts.Var, xt)
+				co.P("%s = %s; // L3790", v.CName, x.CName)
+			}
 		}
-		co.P("      0 ) {")
 		c.Body.VisitStmt(co)
 		co.P("  } else ")
 		co.FinishScope()
 	}
 	co.P("  {")
-	if sws.Default != nil {
-		sws.Default.VisitStmt(co)
+	if ts.Default != nil {
+		co.StartScope("VisitTypeDefault")
+		if ts.Var != "" {
+			v := co.DefineLocal(Serial("v"), ts.Var, xt)
+			co.P("%s = %s; // L3802", v.CName, x.CName)
+		}
+		ts.Default.VisitStmt(co)
+		co.FinishScope()
 	}
 	co.P("  }")
 	co.P("  }")
+	co.BreakTo = savedB
+	co.P("Break_%s: ;", label)
+	co.FinishScope()
+}
+
+// IsNilC is C for an interface (empty or not) being nil.
+func (co *Compiler) IsNilC(x *GDef) string {
+	if x.typeof == AnyTO {
+		return F("((%s).typecode == 0)", x.CName)
+	}
+	return F("(%s == 0)", x.CName)
+}
+
+// ClassC is C for the heap class of the struct pointer
+// in an interface (empty or not), or 0 if it does not hold one.
+func (co *Compiler) ClassC(x *GDef) string {
+	if x.typeof == AnyTO {
+		return F("AnyClass(%s)", x.CName)
+	}
+	return F("HandleClass((word)(%s))", x.CName)
+}
+
+// HasDynamicTypeC is C for the interface x holding a value of type tv.
+func (co *Compiler) HasDynamicTypeC(x *GDef, tv TypeValue) string {
+	if tv == AnyTO {
+		return F("!%s", co.IsNilC(x))
+	}
+	switch t := tv.(type) {
+	case *InterfaceTV:
+		return F("Implements__%s(%s)", co.RegisterImplements(t.InterfaceRec), co.ClassC(x))
+	case *PointerTV:
+		if st, ok := t.E.(*StructTV); ok {
+			return F("(%s == CLASS_%s)", co.ClassC(x), st.StructRec.cname)
+		}
+	case *NamedTV:
+		if x.typeof != AnyTO {
+			// Boxed in the non-empty interface.
+			return F("(%s == CLASS_%s)", co.ClassC(x), t.cname)
+		}
+	}
+	if x.typeof != AnyTO {
+		panic(F("impossible type %v for interface %v", tv, x.typeof))
+	}
+	return F("((%s).typecode && !strcmp(%q, (%s).typecode))",
+		x.CName, DynamicTypeCode(tv), x.CName)
+}
+
+// AssignDynamicType assigns the value in the interface x
+// to toCName, of type tv, after HasDynamicTypeC said it holds one.
+func (co *Compiler) AssignDynamicType(x *GDef, toCName string, tv TypeValue) {
+	switch {
+	case tv.Equals(x.typeof):
+		co.P("%s = %s; // L3844", toCName, x.CName)
+	case tv == AnyTO:
+		co.ConvertToCNameType(x, toCName, tv)
+	case x.typeof != AnyTO && IsNamedTV(tv):
+		co.P("%s = *(%s*)(%s); // L3846 [unbox]", toCName, tv.CType(), x.CName)
+	case x.typeof != AnyTO:
+		co.P("%s = (%s)(%s); // L3848", toCName, tv.CType(), x.CName)
+	default:
+		switch tv.(type) {
+		case *InterfaceTV, *PointerTV:
+			// Both are held in an Any as a pointer to a handle.
+			co.P("%s = (%s)*(word*)(%s).pointer; // L3853", toCName, tv.CType(), x.CName)
+		default:
+			co.P("%s = *(%s*)(%s).pointer; // L3855", toCName, tv.CType(), x.CName)
+		}
+	}
+}
+
+// RegisterImplements asks for Implements__<cname> to be emitted
+// for the interface, and returns its cname.
+func (co *Compiler) RegisterImplements(irec *InterfaceRec) string {
+	cg := co.CGen
+	if _, ok := cg.implements[irec.cname]; !ok {
+		cg.implements[irec.cname] = irec
+		cg.dynamicDefs = append(cg.dynamicDefs,
+			F("extern bool Implements__%s(byte cls); //L3867", irec.cname))
+	}
+	return irec.cname
+}
+
+// VisitSelect evaluates all the chans and values to send, in order,
+// fills a C array of SelectCase, and switches on the index
+// of the case that the runtime Select did.
+func (co *Compiler) VisitSelect(sel *SelectS) {
+	label := Serial("select")
+	co.StartScope("VisitSelect")
+	n := len(sel.Cases)
+	cases := "cases_" + label
+	co.P("  { SelectCase %s[%d]; // L3770", cases, n+1) // +1 avoids an empty array.
+
+	// Received values, and their ok, for each case.
+	recvs := make([]*GDef, n)
+	oks := make([]*GDef, n)
+	for i, c := range sel.Cases {
+		switch comm := c.Comm.(type) {
+		case *SendS:
+			ch := co.Reify(comm.Chan.VisitExpr(co))
+			chanT, ok := Underlying(ch.Type()).(*ChanTV)
+			if !ok {
+				panic(F("cannot send to non-chan %v", ch))
+			}
+			val := co.DefineLocalTempV(Serial("send"), chanT.E, comm.Value.VisitExpr(co))
+			co.P("%s[%d].c = %s; %s[%d].data = &%s; %s[%d].send = 1;",
+				cases, i, ch.ToC(), cases, i, val.CName, cases, i)
+		case *AssignS:
+			if len(comm.B) != 1 || !IsRecv(comm.B[0]) || len(comm.A) > 2 {
+				panic(F("select case must receive or send; got %v", comm))
+			}
+			ch := co.Reify(comm.B[0].(*RecvX).Chan.VisitExpr(co))
+			chanT, ok := Underlying(ch.Type()).(*ChanTV)
+			if !ok {
+				panic(F("cannot receive from non-chan %v", ch))
+			}
+			recvs[i] = co.DefineLocalTempC(Serial("recv"), chanT.E, "")
+			co.P("%s[%d].c = %s; %s[%d].data = &%s; %s[%d].send = 0;",
+				cases, i, ch.ToC(), cases, i, recvs[i].CName, cases, i)
+			if len(comm.A) == 2 {
+				oks[i] = co.DefineLocalTempC(Serial("recv_ok"), BoolTO, "")
+			}
+		default:
+			panic(F("select case must receive or send; got %v", c.Comm))
+		}
+	}
+
+	co.P("  switch (Select(%s, %d, %d)) {", cases, n, CBool(sel.Default != nil))
+	savedB := co.BreakTo
+	co.BreakTo = "Break_" + label
+	for i, c := range sel.Cases {
+		co.StartScope("VisitSelectCase")
+		co.P("  case %d: {", i)
+		if comm, ok := c.Comm.(*AssignS); ok && len(comm.A) > 0 {
+			if oks[i] != nil {
+				co.P("%s = %s[%d].ok;", oks[i].CName, cases, i)
+			}
+			results := []*GDef{recvs[i], oks[i]}
+			for j, a := range comm.A {
+				if id, ok := a.(*IdentX); ok && id.X == "_" {
+					continue
+				}
+				if comm.Op == ":=" {
+					id, ok := a.(*IdentX)
+					if !ok {
+						log.Panicf("Expected an identifier in LHS of `:=` but got %v", a)
+					}
+					local := co.DefineLocal("v", id.X, results[j].typeof)
+					co.P("%s = %s;", local.CName, results[j].CName)
+				} else {
+					co.AssignSingle(a.VisitExpr(co), results[j])
+				}
+			}
+		}
+		c.Body.VisitStmt(co)
+		co.P("  } break;")
+		co.FinishScope()
+	}
+	if sel.Default != nil {
+		co.P("  default: {")
+		sel.Default.VisitStmt(co)
+		co.P("  } break;")
+	}
+	co.BreakTo = savedB
+	co.P("  }}")
+	co.P("Break_%s: {}", label)
 	co.FinishScope()
 }
+
+func CBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 func (co *Compiler) VisitBlock(a *Block) {
 	co.StartScope("VisitBlock")
 	if a == nil {
@@ -3315,7 +5426,20 @@ func (co *Compiler) FindName(name string) *GDef {
 			L("nando q: %q [locals %v] %s", name, q.locals, q.why)
 			q = q.parent
 		}
-		return p.Find(name)
+		gd := p.Find(name)
+		if co.Outer != nil {
+			if _, own := co.slots[gd.CName]; !own {
+				if _, outer := co.Outer.slots[gd.CName]; outer {
+					// A lambda uses its parent's variable through `up`.
+					return &GDef{
+						name:   gd.name,
+						CName:  F("(up->fr_%s)", gd.CName),
+						typeof: gd.typeof,
+					}
+				}
+			}
+		}
+		return gd
 	}
 	L("nando p==nil: %q [cmod @%q]", name, co.CMod.Package)
 	return co.CMod.Find(name)
@@ -3375,10 +5499,16 @@ func (co *Compiler) StartScope(why string) *Block {
 	return block
 }
 func (co *Compiler) EmitFunc(gd *GDef, justDeclare bool) {
-	co.StartScope("EmitFunc")
+	co.funcBlock = co.StartScope("EmitFunc")
 	rec := gd.typeof.(*FunctionTV).FuncRec
-	co.P(rec.SignatureStr(gd.CName, false))
+	isLambda := co.Outer != nil
+	// Lambdas take a pointer to their parent's frame as first arg.
+	sig := rec.SignatureStr(gd.CName, isLambda)
+	if isLambda {
+		sig = "static " + sig
+	}
 	if justDeclare {
+		co.P(sig)
 		co.FinishScope()
 		co.P("; //L2432: justDeclare")
 		return
@@ -3399,7 +5529,7 @@ func (co *Compiler) EmitFunc(gd *GDef, justDeclare bool) {
 		}
 	}
 
-	// Figure out the names of Func outputs, and create locals for them.
+	// Figure out the names of Func outputs.
 	// Unless there is only one out -- then it is a direct return.
 	if len(rec.Outs) > 1 {
 		for i, out := range rec.Outs {
@@ -3409,13 +5539,25 @@ func (co *Compiler) EmitFunc(gd *GDef, justDeclare bool) {
 			} else {
 				name = Format("__%d", i)
 			}
-			out := co.DefineLocal("out", name, out.TV)
-			co.results = append(co.results, NameTV{out.CName, out.typeof})
+			// These are pointers, declared in the formal params.
+			co.results = append(co.results, NameTV{"out_" + name, out.TV})
+		}
+	}
+	// Named results are ordinary locals, zeroed with the frame,
+	// and copied out when the function returns.
+	if len(rec.Outs) > 0 && rec.Outs[0].name != "" {
+		for _, out := range rec.Outs {
+			name := out.name
+			if name == "_" {
+				name = Serial("result")
+			}
+			co.namedResults = append(co.namedResults, co.DefineLocal("v", name, out.TV))
 		}
 	}
 
 	if rec.FuncRecX.Body == nil {
 		// Function has no body, so it should be natively-defined.
+		co.P(sig)
 		co.P("; //EmitFunc L2438: NATIVE\n")
 		return
 	}
@@ -3425,67 +5567,128 @@ func (co *Compiler) EmitFunc(gd *GDef, justDeclare bool) {
 	// Then define the local variables, and initialize them,
 	// and emit them before you emit the body from the buffer.
 
-	co.P("{\n")
-
 	prevBuf := co.Buf
 	co.Buf = &Buf{}
 
 	rec.FuncRecX.Body.compiler = co // TODO ???
 	rec.FuncRecX.Body.VisitStmt(co)
+	co.EmitRunDefers() // In case it falls off the end.
 	cBody := co.Buf.String()
 
 	co.Buf = prevBuf
-	co.P("// Adding LOCALS to Func:")
-	co.P("struct { TOP_FRAME_FIELDS")
+
+	// If there are lambdas, they need to see this frame,
+	// so it must have a named struct type,
+	// and parameters must be copied into it.
+	hasLambdas := len(co.lambdas) > 0
+	var fields, defines, copies []string
 
 	// GC only runs on 6809, so use "cmoc" sizes.
 	offset := 6     // Size of TOP_FRAME_FIELDS
 	var marks []int // Mark offsets for GC.
 
 	for name, e := range co.slots {
-		if strings.HasPrefix(e.CName, "in_") || strings.HasPrefix(e.CName, "out_") {
-			// These are declared in the formal params of the C function.
-			// TODO: copy `in_...` variables to struct, and protect them.
-			continue
+		if strings.HasPrefix(e.CName, "in_") {
+			if !hasLambdas {
+				// These are declared in the formal params of the C function.
+				// TODO: copy `in_...` variables to struct, and protect them.
+				continue
+			}
+			copies = append(copies, F("fr.fr_%s = %s;", e.CName, e.CName))
 		}
-		co.P("// LOCAL %q IS %v", name, e)
-		//< co.P("auto %v %v = %s; // DEF LOCAL L2145 Type=%#v", e.typeof.CType(), e.CName, e.typeof.Zero(), e.typeof)
-		co.P(" %s fr_%s; // DEF LOCAL L2145 Type=%#v", e.typeof.CType(), e.CName, e.typeof)
-		co.P("#define %s fr.fr_%s", e.CName, e.CName)
+		fields = append(fields, F("// LOCAL %q IS %v", name, e))
+		ctype := e.typeof.CType()
+		if e == co.deferCount {
+			// It changes after setjmp, and is read after longjmp.
+			ctype = "volatile " + ctype
+		}
+		fields = append(fields, F(" %s fr_%s; // DEF LOCAL L2145 Type=%#v", ctype, e.CName, e.typeof))
+		defines = append(defines, e.CName)
+
+		for _, tv := range ArrayElements([]TypeValue{e.typeof}) {
+			tcode := tv.TypeCode()
+			switch tcode[0] {
+			case 's': // string
+				marks = append(marks, offset)
+				offset += 6
+			case 'S': // Slice
+				marks = append(marks, offset)
+				offset += 6
+			case 'a': // Any
+				// Any does not mark against GC.
+				offset += 4
+			case 'F': // Function
+				// The env may be a heap object, such as a bound receiver.
+				marks = append(marks, offset+2)
+				offset += 4
+			case 'I': // Interface
+				marks = append(marks, offset)
+				offset += 2
+			case 'M': // Map
+				marks = append(marks, offset)
+				offset += 2
+			case 'C': // Chan
+				marks = append(marks, offset)
+				offset += 2
+			case 'P': // Pointer
+				marks = append(marks, offset)
+				offset += 2
+			case 'b',
+				'z':
+				offset += 1
+			case 'i',
+				'u',
+				'k',
+				'p':
+				offset += 2
+			default:
+				log.Panicf("Unknown TypeCode: %s", tcode)
+			}
+		}
+	}
 
-		tcode := e.typeof.TypeCode()
-		switch tcode[0] {
-		case 's': // string
-			marks = append(marks, offset)
-			offset += 6
-		case 'S': // Slice
-			marks = append(marks, offset)
-			offset += 6
-		case 'a': // Any
-			// Any does not mark against GC.
-			offset += 4
-		case 'F': // Function
-			offset += 2
-		case 'I': // Interface
-			marks = append(marks, offset)
-			offset += 2
-		case 'P': // Pointer
-			marks = append(marks, offset)
-			offset += 2
-		case 'b',
-			'z':
-			offset += 1
-		case 'i',
-			'u',
-			'k',
-			'p':
-			offset += 2
-		default:
-			log.Panicf("Unknown TypeCode: %s", tcode)
+	if hasLambdas {
+		co.P("// Frame for Func with LOCALS, seen by lambdas:")
+		co.P("struct %s { TOP_FRAME_FIELDS", FrameStructName(gd))
+		for _, line := range fields {
+			co.P("%s", line)
+		}
+		co.P("};")
+		for _, lambda := range co.lambdas {
+			co.P("\n%s\n", lambda)
+		}
+	}
+
+	for _, thunk := range co.thunks {
+		co.P("\n%s\n", thunk)
+	}
+
+	co.P(sig)
+	co.P("{\n")
+	co.P("// Adding LOCALS to Func:")
+	if hasLambdas {
+		co.P("struct %s fr;", FrameStructName(gd))
+	} else {
+		co.P("struct { TOP_FRAME_FIELDS")
+		for _, line := range fields {
+			co.P("%s", line)
 		}
+		co.P("} fr;")
 	}
-	co.P("} fr;")
 	co.P("memset(&fr, 0, sizeof(fr));")
+	for _, line := range copies {
+		co.P("%s", line)
+	}
+	for _, cname := range defines {
+		co.P("#define %s fr.fr_%s", cname, cname)
+	}
+	if len(co.Defers) > 0 {
+		co.P("Catcher catcher;")
+	}
+	if isLambda {
+		frameT := FrameStructName(co.Outer.Subject)
+		co.P("struct %s* up = (struct %s*)receiver;", frameT, frameT)
+	}
 
 	co.P("fr.fr_shape = %q;", CompileMarkOffsets(marks))
 
@@ -3509,7 +5712,31 @@ func (co *Compiler) EmitFunc(gd *GDef, justDeclare bool) {
 		co.P("Where();")
 	}
 	co.P("CurrentFrame = fr.fr_prev;")
+	if len(co.Defers) > 0 {
+		co.P("if (0) {")
+		co.P("Panicked: {} // L2700: unwinding")
+		co.P("CurrentFrame = (struct Frame*) &fr;")
+		co.EmitRunDefers()
+		co.P("Repanic(); // unless recovered")
+		if co.namedResults != nil {
+			co.EmitReturnNamedResults()
+		} else if co.zeroResult != nil {
+			co.P("RETURN %s;", co.zeroResult.CName)
+		} else {
+			co.P("RETURN_NOTHING;")
+		}
+		co.P("}")
+	}
 	co.P("\n}\n")
+	// Lambdas and other functions may follow in the same C file.
+	for _, cname := range defines {
+		co.P("#undef %s", cname)
+	}
+}
+
+// FrameStructName names the struct type of the frame of a func with lambdas.
+func FrameStructName(gd *GDef) string {
+	return "Frame_" + gd.CName
 }
 
 func CompileMarkOffsets(marks []int) []byte {
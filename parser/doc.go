@@ -9,7 +9,9 @@ Cannot reuse builtin names.  Cannot reuse global names.
 
 `const` and `type` are only allowed at outer level.
 
-`const` are untyped bool, integer, or string.
+`const` are bool, integer, or string, and may be typed,
+like `const Mask byte = 0x7F`.  Constant expressions are folded
+at compile time, including string concatenation.
 
 `type` is only used to define struct and interface.
 Structs can only be defined by `type` at the global level;
@@ -30,14 +32,18 @@ Pointers are never used except for handles to structs.
 Structs cannot be embedded -- they must be the toplevel thing
 in a GC Heap object.
 
-Methods are defined only on *struct, never on struct,
-never on non-struct (since you can't define non-struct types
-with `type`).
+Methods are defined on *struct, never on struct.
+Defined types over non-struct types (like `type Celsius int`,
+`type Path []string`, or `type Handler func(int) int`) have the
+representation of their underlying type, and can have methods
+with value receivers.
 
 The GC Heap contains two hidden values for each allocation: its length
 and its "class".  The length can be greater than the actual ask, so
 that lengths can come from a small set of sizes (and there can be a free
-list for each size).  The "class" is a byte that identifies the type of
+list for each size).  The length is a word, so an object can be
+bigger than 255 bytes (like a 1K disk sector in a []byte), up to
+MAX_OBJ_LEN in the runtime.  The "class" is a byte that identifies the type of
 the object.  Class is required in order for Garbage Collection to know
 where handles are inside the object, but can also be used by languages
 for dynamic dispatch of methods.  This subset of Go will use "class"
@@ -54,16 +60,37 @@ Lambdas can only be defined at the first level in a func, and they cannot
 be used after their parent scope is gone -- that is, they reference
 variables directly on the C stack.
 
+Func values are pairs {fn, env}.  A lambda's env is its parent's frame.
+A global func used as a value gets a small static func that skips the env.
+A method value like `f := obj.Meth` binds the receiver in env
+(a box for a defined non-struct type).  The GC marks the env
+when it is in the GC Heap, so a bound receiver stays alive;
+a lambda's frame is on a C stack, outside the heap, and is skipped.
+Funcs can only be compared with nil.
+
 `defer` can only be used at the first level in a func.  So there is no
 problem using `defer` to recover as the first thing in a function, or
 using `defer` to close files that are opened in the first level of a func.
+As in Go, recover() only stops a panic when the deferred func calls it
+directly.  A panic longjmps back to the func's setjmp, so only the count
+of defers reached is volatile; other locals stay in its frame in memory.
 
 Slices are triples {handle, offset, length} as in normal Go.  The handle
 is to GC Heap object of an internal struct type.
 
+Arrays `[N]T` are values, like in Go, and can be struct fields,
+for small fixed buffers without another GC Heap object.
+Only an array in a struct field can be sliced, like `p.buf[:n]`,
+since the slice must refer to the GC Heap object holding it.
+Such a slice does not know where the array ends, so `append`
+to it always copies, even if the array has room.
+
 Strings are like slices, triples {handle, offset, length}.  To make
 literal strings cheaper, we may allow the handle to be nil, and the
 offset to locate a literal C string in a readonly OS9 module.
+Strings hold any bytes, including zero bytes, and are slicable
+like `s[a:b]`.  A `rune` is an int, so only 16-bit code points
+survive `[]rune(s)` and `string(r)`; others become U+FFFD.
 
 Maps are simple handles to a GC Heap object of an internal
 struct type.
@@ -71,22 +98,33 @@ struct type.
 Interfaces can be implemented as two cases:
 (1) `interface {}`
 (2) interfaces that point to structs.
-This is because only structs and interfaces can be defined with `type`.
 Case 1 will have to be big enough to hold a slice or string triple
 and a type pointer.  Case 2 only needs to hold a handle, since
 we can ask the class of the Heap object referenced by the handle.
-
-Channels are not supported (yet).
-When they are supported, they will be a simple handle to a GC Heap object
-of an internal struct type.
-
-Goroutines are not supported (yet).
-When they are supported, they will be global function calls
-or method invocations, not lambdas (since lambdas do not
-survive their scope).  They will have to have their own C stack.
+A value of a defined non-struct type is copied into a "box",
+a Heap object with a class for that type, to be put in case 2.
+In case 1 its typecode is `<underlying>@<cname>;`, the typecode of its
+underlying type followed by the C name of the defined type, so a type
+switch can tell `Celsius` from `int`.
+
+Channels are a handle to a GC Heap object of class Chan, which holds
+the buffered elements and the queues of waiting goroutines.
+They can be buffered or not, closed, ranged over, and received with
+comma-ok.  `select` can send or receive on several channels, with
+an optional `default`; time.After(ms) gives a channel for timeouts.
+
+Goroutines are scheduled cooperatively: one runs until it blocks
+on a channel, sleeps, or exits, so no locks are needed.  Each has its
+own C stack (on OS-9, GO_MAX stacks of GO_STACK_SIZE bytes).
+`go` only takes a global func or a method call, not a lambda,
+since a lambda uses its parent's frame, which may be gone before the
+goroutine runs.  (A func variable holding a lambda is not caught.)
+The func and its args are copied into the goroutine, in at most
+GO_ARGS_WORDS words.
 
 No renaming imports.  No "/" in import names (use a flat space).
-No groups with ( and ) for imports, const, var, or type.
-No types for enums.  No definitions with iota.
+Groups with ( and ) are allowed for imports, const, var, and type,
+and `iota` counts the lines in a const group.
+Enums are consts of a defined int type, like `const Red Color = iota`.
 
 */
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strconv"
+	"unicode/utf8"
 )
 
 const LF = 10 // man 7 ascii
@@ -110,7 +112,19 @@ func (o *Lex) _Next_() {
 					o.Kind, o.Word = L_EOL, "//EOL//"
 					return
 				}
+				if z == 0 {
+					o.Kind, o.Word, o.AtEof = L_EOL, "//EOL//", true
+					return
+				}
 			}
+		} else if c2 == '*' {
+			if o.SkipBlockComment() {
+				// Like Go, a comment with newlines acts like a newline.
+				o.Kind, o.Word = L_EOL, "/*EOL*/"
+				return
+			}
+			o._Next_()
+			return
 		} else {
 			o.UnReadChar(c2)
 		}
@@ -129,25 +143,8 @@ func (o *Lex) _Next_() {
 		}
 	*/
 	if '0' <= c && c <= '9' {
-		x := int(c - '0')
-		c = o.ReadChar()
-		for '0' <= c && c <= '9' {
-			if x == 0 {
-				panic("no octal")
-			}
-			x = 10*x + int(c-'0')
-			c = o.ReadChar()
-		}
-		o.UnReadChar(c)
-		/*
-			if neg {
-				o.Kind, o.Num = L_Int, -x
-			} else {
-		*/
+		x := o.LexNumber(c)
 		o.Kind, o.Num, o.Word = L_Int, x, fmt.Sprintf("%d", x)
-		/*
-			}
-		*/
 		return
 	}
 	if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || c == '_' {
@@ -163,88 +160,90 @@ func (o *Lex) _Next_() {
 		return
 	}
 	if c == '"' {
+		line, col := o.Line, o.Col
 		var s []byte
 		c = o.ReadChar()
 		for c != '"' {
+			if c == 0 || c == LF || c == CR {
+				o.PanicAt(line, col, "unterminated string literal")
+			}
 			if c == '\\' {
-				c = o.ReadChar()
-				if c == 'n' {
-					c = '\n'
-				} else if '0' <= c && c <= '7' {
-					c2 := o.ReadChar()
-					c3 := o.ReadChar()
-					if !('0' <= c2 && c2 <= '7') || !('0' <= c3 && c3 <= '7') {
-						panic("bad octal in str")
-					}
-					c = byte(64*int(c-'0') + 8*int(c2-'0') + int(c3-'0'))
+				r, isByte := o.LexEscape('"')
+				if isByte {
+					s = append(s, byte(r))
+				} else {
+					s = append(s, string(r)...)
 				}
+			} else {
+				s = append(s, c)
 			}
-			s = append(s, c)
 			c = o.ReadChar()
 		}
 		o.Kind, o.Word = L_String, string(s)
 		return
 	}
-	if c == '\'' {
+	if c == '`' {
+		line, col := o.Line, o.Col
 		var s []byte
 		c = o.ReadChar()
+		for c != '`' {
+			if c == 0 {
+				o.PanicAt(line, col, "unterminated raw string literal")
+			}
+			if c != CR { // Go discards CRs in raw strings.
+				s = append(s, c)
+			}
+			c = o.ReadChar()
+		}
+		o.Kind, o.Word = L_String, string(s)
+		return
+	}
+	if c == '\'' {
+		line, col := o.Line, o.Col
+		var s []byte // The literal's UTF-8 bytes, if not escaped.
+		var r rune
+		n := 0 // How many runes.
+		c = o.ReadChar()
 		for c != '\'' {
+			if c == 0 || c == LF || c == CR {
+				o.PanicAt(line, col, "unterminated rune literal")
+			}
 			if c == '\\' {
-				c = o.ReadChar()
-
-				switch c {
-				case 'a':
-					c = '\a'
-				case 'b':
-					c = '\b'
-				case 'f':
-					c = '\f'
-				case 'n':
-					c = '\n' // We are still on UNIX for now.
-				case 'r':
-					c = '\r'
-				case 't':
-					c = '\t'
-				case 'v':
-					c = '\v'
-				case '\\':
-					c = '\\'
-				case '\'':
-					c = '\''
-				case '"':
-					c = '"'
-				case 'x':
-					c2 := o.ReadChar()
-					c3 := o.ReadChar()
-					c = (hexval(c2) << 4) | hexval(c3)
-
-				default:
-					if '0' <= c && c <= '7' {
-						c2 := o.ReadChar()
-						c3 := o.ReadChar()
-						if !('0' <= c2 && c2 <= '7') || !('0' <= c3 && c3 <= '7') {
-							panic("bad octal in char literal")
-						}
-						c = (octval(c) << 6) | (octval(c2) << 3) | octval(c3)
-					}
+				r, _ = o.LexEscape('\'')
+				n++
+			} else {
+				s = append(s, c)
+				if utf8.FullRune(s) {
+					r, _ = utf8.DecodeRune(s)
+					s = nil
+					n++
 				}
-
 			}
-			s = append(s, c)
 			c = o.ReadChar()
 		}
-		if len(s) != 1 {
-			log.Panicf("bad char literal: %q", s)
+		if n != 1 || len(s) != 0 {
+			o.PanicAt(line, col, "rune literal must have exactly one character")
 		}
-		o.Kind, o.Word = L_Char, string(s)
+		o.Kind, o.Num, o.Word = L_Char, int(r), string(r)
 		return
 	}
 
 	d := o.ReadChar()
 	for _, digraph := range []string{
-		"..", "++", "--", ":=", "<=", "<<", ">=", ">>", "==", "!=", "+=", "-=", "*="} {
+		"..", "++", "--", ":=", "<=", "<<", "<-", ">=", ">>", "==", "!=",
+		"&&", "||", "&^", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^="} {
 		if c == digraph[0] && d == digraph[1] {
 			o.Kind, o.Word = L_Punc, digraph
+			switch digraph {
+			case "<<", ">>", "&^":
+				// These may be followed by `=`, as in `<<=`.
+				e := o.ReadChar()
+				if e == '=' {
+					o.Word = digraph + "="
+				} else {
+					o.UnReadChar(e)
+				}
+			}
 			return
 		}
 	}
@@ -254,20 +253,128 @@ func (o *Lex) _Next_() {
 	return
 }
 
-func octval(x byte) byte {
-	if '0' <= x && x <= '7' {
-		return x - '0'
+// LexNumber reads an integer literal starting with the digit c,
+// in any of Go's forms: decimal, 0x hex, 0o or legacy 0 octal,
+// or 0b binary, with optional `_` separators between digits.
+func (o *Lex) LexNumber(c byte) int {
+	var s []byte
+	for '0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || c == '_' {
+		s = append(s, c)
+		c = o.ReadChar()
+	}
+	o.UnReadChar(c)
+	// Base 0 means Go syntax, including the rules for underscores.
+	x, err := strconv.ParseInt(string(s), 0, 64)
+	if err != nil {
+		if e, ok := err.(*strconv.NumError); ok && e.Err == strconv.ErrRange {
+			o.Panicf("integer literal too large: %s", s)
+		}
+		o.Panicf("bad integer literal: %s", s)
 	}
-	panic(F("Not an octal char: %q", []byte{x}))
+	return int(x)
 }
 
-func hexval(x byte) byte {
-	if '0' <= x && x <= '9' {
-		return x - '0'
-	} else if 'a' <= x && x <= 'f' {
-		return x - 'a' + 10
-	} else if 'A' <= x && x <= 'F' {
-		return x - 'A' + 10
+// SkipBlockComment skips to the end of a `/* ... */` comment,
+// after the opening `/*`, and tells if the comment had a newline.
+func (o *Lex) SkipBlockComment() bool {
+	line, col := o.Line, o.Col
+	newline := false
+	prev := byte(0)
+	for {
+		c := o.ReadChar()
+		switch {
+		case c == 0:
+			o.PanicAt(line, col, "unterminated comment")
+		case c == LF || c == CR:
+			newline = true
+		case c == '/' && prev == '*':
+			return newline
+		}
+		prev = c
 	}
-	panic(F("Not a hex char: %q", []byte{x}))
+}
+
+// LexEscape reads an escape sequence, after the `\`, in a literal
+// quoted by q.  Octal and \x escapes are bytes; the others are runes.
+func (o *Lex) LexEscape(q byte) (r rune, isByte bool) {
+	c := o.ReadChar()
+	switch c {
+	case 'a':
+		return '\a', false
+	case 'b':
+		return '\b', false
+	case 'f':
+		return '\f', false
+	case 'n':
+		return '\n', false // We are still on UNIX for now.
+	case 'r':
+		return '\r', false
+	case 't':
+		return '\t', false
+	case 'v':
+		return '\v', false
+	case '\\':
+		return '\\', false
+	case 'x':
+		return o.LexEscapeDigits(2, 16), true
+	case 'u':
+		return o.LexUnicodeEscape(4), false
+	case 'U':
+		return o.LexUnicodeEscape(8), false
+	}
+	if c == q {
+		return rune(c), false
+	}
+	if '0' <= c && c <= '7' {
+		o.UnReadChar(c)
+		r := o.LexEscapeDigits(3, 8)
+		if r > 255 {
+			o.Panicf("octal escape value > 255: %d", r)
+		}
+		return r, true
+	}
+	o.Panicf("unknown escape sequence: \\%c", c)
+	panic(0)
+}
+
+func (o *Lex) LexUnicodeEscape(n int) rune {
+	r := o.LexEscapeDigits(n, 16)
+	if !utf8.ValidRune(r) {
+		o.Panicf("escape sequence is invalid Unicode code point: %#x", r)
+	}
+	return r
+}
+
+// LexEscapeDigits reads exactly n digits in the base.
+func (o *Lex) LexEscapeDigits(n int, base rune) rune {
+	var r rune
+	for i := 0; i < n; i++ {
+		c := o.ReadChar()
+		var d rune
+		switch {
+		case '0' <= c && c <= '9':
+			d = rune(c - '0')
+		case 'a' <= c && c <= 'f':
+			d = rune(c-'a') + 10
+		case 'A' <= c && c <= 'F':
+			d = rune(c-'A') + 10
+		default:
+			d = base // not a digit
+		}
+		if d >= base {
+			o.Panicf("expected %d digits in base %d in escape sequence", n, base)
+		}
+		r = r*base + d
+	}
+	return r
+}
+
+// Panicf reports an error at the current position in the source.
+func (o *Lex) Panicf(format string, args ...interface{}) {
+	o.PanicAt(o.Line, o.Col, format, args...)
+}
+
+// PanicAt reports an error at a position in the source.
+func (o *Lex) PanicAt(line, col int, format string, args ...interface{}) {
+	panic(fmt.Sprintf("%s:%d:%d: ", o.Filename, line, col) + fmt.Sprintf(format, args...))
 }
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLexNumbers(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want int
+	}{
+		{"0", 0},
+		{"42", 42},
+		{"0xFF00", 0xFF00},
+		{"0XfF", 255},
+		{"0755", 0755},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000", 1000},
+		{"0x_FF_00", 0xFF00},
+	} {
+		lex := NewLex(bytes.NewBufferString(tc.src), "TEST")
+		if lex.Kind != L_Int || lex.Num != tc.want {
+			t.Errorf("%q: got (%d) %d, want %d", tc.src, lex.Kind, lex.Num, tc.want)
+		}
+	}
+}
+
+func TestLexBadNumbers(t *testing.T) {
+	for _, src := range []string{"09", "0x", "1__0", "0b2", "12abc", "99999999999999999999"} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Errorf("%q: expected error", src)
+				} else if !strings.HasPrefix(F("%v", r), "TEST:1:") {
+					t.Errorf("%q: error lacks position: %v", src, r)
+				}
+			}()
+			NewLex(bytes.NewBufferString(src), "TEST")
+		}()
+	}
+}
+
+func TestLexStrings(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		kind int
+		want string
+	}{
+		{`"a\tb"`, L_String, "a\tb"},
+		{`"\"\\"`, L_String, "\"\\"},
+		{`"\x1bA\101"`, L_String, "\x1bAA"},
+		{`"é\U0001F600"`, L_String, "é\U0001F600"},
+		{"`raw\\n\r\nline`", L_String, "raw\\n\nline"},
+		{`'\''`, L_Char, "'"},
+		{`'é'`, L_Char, "é"},
+		{"/* c */ \"x\"", L_String, "x"},
+		{"/* c \n */ \"x\"", L_EOL, "/*EOL*/"},
+	} {
+		lex := NewLex(bytes.NewBufferString(tc.src), "TEST")
+		if lex.Kind != tc.kind || lex.Word != tc.want {
+			t.Errorf("%q: got (%d) %q, want (%d) %q", tc.src, lex.Kind, lex.Word, tc.kind, tc.want)
+		}
+	}
+}
+
+func TestLexBadStrings(t *testing.T) {
+	for _, src := range []string{"\"abc\n\"", "\"abc", "`abc", "'ab'", "''", `"\q"`, `"\x4"`, `'\400'`, "/* abc"} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil {
+					t.Errorf("%q: expected error", src)
+				} else if !strings.HasPrefix(F("%v", r), "TEST:1:") {
+					t.Errorf("%q: error lacks position: %v", src, r)
+				}
+			}()
+			NewLex(bytes.NewBufferString(src), "TEST")
+		}()
+	}
+}
@@ -24,6 +24,10 @@ type Parser struct {
 	Types   []*GDef
 	Funcs   []*GDef
 	Meths   []*GDef
+
+	// While parsing a type, `{` cannot start a composite literal,
+	// as it may start the body of a func.
+	inType int
 }
 
 func NewParser(r io.Reader, filename string) *Parser {
@@ -49,7 +53,7 @@ func (o *Parser) ParsePrim() Expr {
 		return z
 	}
 	if o.Kind == L_Char {
-		z := &LitIntX{int(o.Word[0])}
+		z := &LitIntX{o.Num}
 		o.Next()
 		return z
 	}
@@ -64,6 +68,34 @@ func (o *Parser) ParsePrim() Expr {
 		if o.Word == "struct" {
 			panic("Keyword `struct` not expected, except after global `type`")
 		}
+		if o.Word == "func" {
+			// Either a function literal (lambda) or a function type.
+			o.Next()
+			fn := &FuncRecX{}
+			o.ParseFunctionSignature(fn)
+			if o.Word == "{" {
+				fn.Body = o.ParseBlock()
+				return &FunctionX{fn}
+			}
+			return &FunctionTX{fn}
+		}
+		if o.Word == "map" {
+			o.Next()
+			o.TakePunc("[")
+			keyX := o.ParseType()
+			o.TakePunc("]")
+			valueX := o.ParseType()
+			return &MapTX{o.ExprToNameTX(keyX), o.ExprToNameTX(valueX)}
+		}
+		if o.Word == "chan" {
+			// The direction of `chan<- T` is not checked.
+			o.Next()
+			if o.Word == "<-" {
+				o.Next()
+			}
+			elemX := o.ParseType()
+			return &ChanTX{o.ExprToNameTX(elemX)}
+		}
 		z := &IdentX{o.Word, o.CMod}
 		o.Next()
 		return z
@@ -74,11 +106,26 @@ func (o *Parser) ParsePrim() Expr {
 			x := o.ParsePrim()
 			return &BinOpX{&LitIntX{0}, "-", x}
 		}
+		if o.Word == "!" || o.Word == "^" {
+			op := o.Word
+			o.Next()
+			x := o.ParsePrimEtc()
+			return &UnaryX{op, x}
+		}
 		if o.Word == "*" {
 			o.Next()
 			elemX := o.ParseType()
 			return &PointerTX{o.ExprToNameTX(elemX)}
 		}
+		if o.Word == "<-" {
+			o.Next()
+			if o.Word == "chan" {
+				// The direction of `<-chan T` is not checked.
+				return o.ParsePrim()
+			}
+			x := o.ParsePrimEtc()
+			return &RecvX{x}
+		}
 		if o.Word == "(" {
 			o.Next()
 			ex := o.ParseExpr()
@@ -87,9 +134,29 @@ func (o *Parser) ParsePrim() Expr {
 		}
 		if o.Word == "[" {
 			o.Next()
+			if o.Word != "]" {
+				// Array type, with a constant length.
+				n := o.ParseExpr()
+				o.TakePunc("]")
+				elemX := o.ParseType()
+				arrayX := &ArrayTX{n, o.ExprToNameTX(elemX)}
+				if o.Word == "{" && o.inType == 0 {
+					return o.ParseConstructor(arrayX)
+				}
+				return arrayX
+			}
 			o.TakePunc("]")
 			elemX := o.ParseType()
-			return &SliceTX{o.ExprToNameTX(elemX)}
+			if call, ok := elemX.(*CallX); ok && o.inType == 0 {
+				// A conversion like `[]byte(s)` parsed its call
+				// into the element type; move the call outside.
+				return &CallX{&SliceTX{o.ExprToNameTX(call.Func)}, call.Args, call.HasDotDotDot}
+			}
+			sliceX := &SliceTX{o.ExprToNameTX(elemX)}
+			if o.Word == "{" && o.inType == 0 {
+				return o.ParseConstructor(sliceX)
+			}
+			return sliceX
 		}
 		if o.Word == "&" {
 			o.Next()
@@ -101,6 +168,10 @@ func (o *Parser) ParsePrim() Expr {
 	panic(0)
 }
 
+// ParseConstructor parses the elements of a composite literal,
+// like `&T{x: 1, y: 2}` or `&T{1, 2}` or `[]int{1, 2}`.
+// The typeX is nil for an element literal with its type elided,
+// like `{1, 2}` in `[][]int{{1, 2}}`.
 func (o *Parser) ParseConstructor(typeX Expr) Expr {
 	o.TakePunc("{")
 	ctor := &ConstructorX{
@@ -108,30 +179,41 @@ func (o *Parser) ParseConstructor(typeX Expr) Expr {
 	}
 LOOP:
 	for {
-		switch o.Kind {
-		case L_Ident:
-			fieldName := o.TakeIdent()
-			o.TakePunc(":")
-			fieldX := o.ParseExpr()
-			ctor.inits = append(ctor.inits, NameAndExpr{fieldName, fieldX, o.CMod})
-		case L_EOL:
+		switch o.Word {
+		case "}":
+			break LOOP
+		case ",", ";;":
 			o.Next()
-		case L_Punc:
-			if o.Word == "," {
-				o.Next()
-			} else if o.Word == "}" {
-				break LOOP
-			} else {
-				panic(F("Expected identifier or `}` but got %q", o.Word))
+			continue LOOP
+		}
+		x := o.ParseElement()
+		name := ""
+		if o.Word == ":" {
+			id, ok := x.(*IdentX)
+			if !ok {
+				panic(F("Expected field name before `:` but got %v", x))
 			}
-		default:
-			panic(F("Expected identifier or `,` or `}` but got %q", o.Word))
+			o.TakePunc(":")
+			name, x = id.X, o.ParseElement()
+		}
+		ctor.inits = append(ctor.inits, NameAndExpr{name, x, o.CMod})
+		if o.Word != "," && o.Word != "}" && o.Kind != L_EOL {
+			panic(F("Expected `,` or `}` but got %q", o.Word))
 		}
 	}
 	o.TakePunc("}")
 	return ctor
 }
 
+// ParseElement parses an element of a composite literal,
+// which may be a literal with its type elided.
+func (o *Parser) ParseElement() Expr {
+	if o.Word == "{" {
+		return o.ParseConstructor(nil)
+	}
+	return o.ParseExpr()
+}
+
 func (o *Parser) ParsePrimEtc() Expr {
 	// It starts with a Prim.
 	a := o.ParsePrim()
@@ -184,7 +266,7 @@ LOOP:
 				o.TakePunc("(")
 				b := o.ParseType()
 				o.TakePunc(")")
-				return &TypeAssertX{a, b}
+				a = &TypeAssertX{a, b}
 			} else {
 				member := o.TakeIdent()
 				a = &DotX{a, member}
@@ -257,9 +339,63 @@ func (o *Parser) ParseExpr() Expr {
 }
 
 func (o *Parser) ParseType() Expr {
+	o.inType++
+	defer func() { o.inType-- }()
 	return o.ParseExpr() // ParseType is now ParseExpr.
 }
 
+// ParseTypeSwitchHeader finishes parsing a type switch, if the
+// statement already parsed after `switch` (and its init, if any)
+// is `x.(type)` or `v := x.(type)`.  Otherwise it returns nil.
+func (o *Parser) ParseTypeSwitchHeader(init Stmt, guard *AssignS) *TypeSwitchS {
+	if guard == nil || len(guard.B) != 1 || !IsTypeSwitchGuard(guard.B[0]) {
+		return nil
+	}
+	var name string
+	if guard.Op == ":=" {
+		ident, ok := guard.A[0].(*IdentX)
+		if !ok || len(guard.A) != 1 {
+			panic(F("expected one name before `:=` in type switch; got %v", guard.A))
+		}
+		name = ident.X
+	} else if guard.A != nil {
+		panic(F("expected `:=` before `x.(type)` in switch; got %v", guard))
+	}
+	o.TakePunc("{")
+	ts := &TypeSwitchS{Init: init, Var: name, X: guard.B[0].(*TypeAssertX).X}
+	for o.Word != "}" {
+		for o.Word == ";;" {
+			o.Next()
+		}
+		cOrD := o.TakeIdent()
+		switch cOrD {
+		case "case":
+			matches := o.ParseList()
+			o.TakePunc(":")
+			bare := o.ParseBareBlock()
+			ts.Cases = append(ts.Cases, &Case{matches, bare})
+		case "default":
+			o.TakePunc(":")
+			bare := o.ParseBareBlock()
+			ts.Default = bare
+		default:
+			panic(cOrD)
+		}
+	}
+	o.TakePunc("}")
+	return ts
+}
+
+// IsTypeSwitchGuard tells if x is `x.(type)`.
+func IsTypeSwitchGuard(x Expr) bool {
+	if tass, ok := x.(*TypeAssertX); ok {
+		if ident, ok := tass.T.(*IdentX); ok {
+			return ident.X == "type"
+		}
+	}
+	return false
+}
+
 func (o *Parser) ParseStructType(name string) *StructRecX {
 	o.TakePunc("{")
 	rec := &StructRecX{
@@ -337,20 +473,50 @@ func (o *Parser) ParseAssignment() Stmt {
 		}
 		b := o.ParseList()
 		return &AssignS{a, op, b, isRange}
+	} else if IsCompoundAssignOp(op) {
+		o.Next()
+		b := o.ParseList()
+		return &AssignS{a, op, b, isRange}
 	} else if op == "++" {
 		o.Next()
 		return &AssignS{a, op, nil, isRange}
 	} else if op == "--" {
 		o.Next()
 		return &AssignS{a, op, nil, isRange}
-	} else if o.Kind == L_EOL || o.Word == "{" {
-		// Result not assigned.
+	} else if op == "<-" {
+		o.Next()
+		if len(a) != 1 {
+			panic(F("expected one channel before `<-`; got %v", a))
+		}
+		return &SendS{a[0], o.ParseExpr()}
+	} else if o.Kind == L_EOL || o.Word == "{" || o.Word == ":" {
+		// Result not assigned.  (The ":" is after a `select` case.)
 		return &AssignS{nil, "", a, isRange}
 	} else {
 		panic(F("Unexpected token after statement: %v", o.Word))
 	}
 }
 
+// ParseSwitchGuard parses the simple statement after `switch`
+// (or after its init).  An expression is an AssignS with no A.
+func (o *Parser) ParseSwitchGuard() *AssignS {
+	switch t := o.ParseAssignment().(type) {
+	case *AssignS:
+		return t
+	default:
+		panic(F("unexpected statement after `switch`: %v", t))
+	}
+}
+
+// IsCompoundAssignOp tells if op is like `+=`, and not `:=` or `==`.
+func IsCompoundAssignOp(op string) bool {
+	switch op {
+	case "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<=", ">>=", "&^=":
+		return true
+	}
+	return false
+}
+
 func (o *Parser) TakePunc(s string) {
 	if o.Kind != L_Punc || s != o.Word {
 		panic(F("expected %q, got (%d) %q", s, o.Kind, o.Word))
@@ -454,12 +620,32 @@ func (o *Parser) ParseStmt(b *Block) Stmt {
 
 	case "switch":
 		o.Next()
-		var subject Expr
+		var init Stmt
+		var guard *AssignS
 		if o.Word != "{" {
-			subject = o.ParseExpr()
+			if o.Word != ";" {
+				guard = o.ParseSwitchGuard()
+			}
+			if o.Word == ";" {
+				o.TakePunc(";")
+				init, guard = guard, nil
+				if o.Word != "{" {
+					guard = o.ParseSwitchGuard()
+				}
+			}
+		}
+		if ts := o.ParseTypeSwitchHeader(init, guard); ts != nil {
+			return ts
+		}
+		var subject Expr
+		if guard != nil {
+			if guard.A != nil || len(guard.B) != 1 {
+				panic(F("expected one expression after `switch`; got %v", guard))
+			}
+			subject = guard.B[0]
 		}
 		o.TakePunc("{")
-		sws := &SwitchS{subject, nil, nil}
+		sws := &SwitchS{Init: init, Switch: subject}
 		for o.Word != "}" {
 			for o.Word == ";;" {
 				o.Next()
@@ -474,13 +660,38 @@ func (o *Parser) ParseStmt(b *Block) Stmt {
 			case "default":
 				o.TakePunc(":")
 				bare := o.ParseBareBlock()
-				sws.Default = bare
+				sws.Cases = append(sws.Cases, &Case{nil, bare})
 			default:
 				panic(cOrD)
 			}
 		}
 		o.TakePunc("}")
 		return sws
+	case "select":
+		o.Next()
+		o.TakePunc("{")
+		sel := &SelectS{}
+		for o.Word != "}" {
+			for o.Word == ";;" {
+				o.Next()
+			}
+			cOrD := o.TakeIdent()
+			switch cOrD {
+			case "case":
+				comm := o.ParseAssignment()
+				o.TakePunc(":")
+				bare := o.ParseBareBlock()
+				sel.Cases = append(sel.Cases, &SelectCase{comm, bare})
+			case "default":
+				o.TakePunc(":")
+				bare := o.ParseBareBlock()
+				sel.Default = bare
+			default:
+				panic(cOrD)
+			}
+		}
+		o.TakePunc("}")
+		return sel
 	case "return":
 		o.Next()
 		var xx []Expr
@@ -488,6 +699,22 @@ func (o *Parser) ParseStmt(b *Block) Stmt {
 			xx = o.ParseList()
 		}
 		return &ReturnS{xx}
+	case "defer":
+		o.Next()
+		x := o.ParseExpr()
+		call, ok := x.(*CallX)
+		if !ok {
+			panic(F("expression in defer must be function call; got %v", x))
+		}
+		return &DeferS{call}
+	case "go":
+		o.Next()
+		x := o.ParseExpr()
+		call, ok := x.(*CallX)
+		if !ok {
+			panic(F("expression in go must be function call; got %v", x))
+		}
+		return &GoS{call}
 	case "break":
 		o.Next()
 		break_to := ""
@@ -496,6 +723,9 @@ func (o *Parser) ParseStmt(b *Block) Stmt {
 			o.Next()
 		}
 		return &BreakS{break_to}
+	case "fallthrough":
+		o.Next()
+		return &FallthroughS{}
 	case "continue":
 		o.Next()
 		continue_to := ""
@@ -527,7 +757,9 @@ func (o *Parser) ParseBareBlock() *Block {
 			if stmt != nil {
 				b.stmts = append(b.stmts, stmt)
 			}
-			o.TakeEOL()
+			if o.Word != "}" { // as in a one-line block
+				o.TakeEOL()
+			}
 		}
 	}
 	return b
@@ -536,13 +768,31 @@ func (o *Parser) ParseBareBlock() *Block {
 func (o *Parser) ParseFunctionSignature(fn *FuncRecX) {
 	o.TakePunc("(")
 	for o.Word != ")" {
-		s := o.TakeIdent()
+		// A param is a name and a type, or just a type (in a func type).
+		var s string
+		var t Expr
+		if o.Kind == L_Ident {
+			w := o.TakeIdent()
+			switch o.Word {
+			case ",", ")":
+				t = &IdentX{w, o.CMod}
+			case ".":
+				o.TakePunc(".")
+				t = &DotX{&IdentX{w, o.CMod}, o.TakeIdent()}
+			default:
+				s = w
+			}
+		} else if o.Word != ".." {
+			t = o.ParseType()
+		}
 		if o.Word == ".." {
 			o.TakePunc("..")
 			o.TakePunc(".")
 			fn.HasDotDotDot = true
 		}
-		t := o.ParseType()
+		if t == nil {
+			t = o.ParseType()
+		}
 		Say(t)
 		fn.Ins = append(fn.Ins, NameTX{s, t, o.CMod})
 		if o.Word == "," {
@@ -564,8 +814,7 @@ func (o *Parser) ParseFunctionSignature(fn *FuncRecX) {
 		}
 	}
 	o.TakePunc(")")
-	// this will have to be fixed to parse types
-	if o.Word != "{" && o.Kind != L_EOL {
+	if o.HasResultType() {
 		if o.Word == "(" {
 			o.TakePunc("(")
 			for o.Word != ")" {
@@ -585,6 +834,20 @@ func (o *Parser) ParseFunctionSignature(fn *FuncRecX) {
 		}
 	}
 }
+
+// HasResultType tells whether a result type follows a function's params.
+// A function type can be followed by punctuation that ends it.
+func (o *Parser) HasResultType() bool {
+	if o.Kind == L_EOL {
+		return false
+	}
+	switch o.Word {
+	case "{", "}", ")", "]", ",", "=", ";":
+		return false
+	}
+	return true
+}
+
 func (o *Parser) ParseFunc(receiver *NameTX) *FuncRecX {
 	fn := &FuncRecX{}
 	if receiver != nil {
@@ -599,6 +862,120 @@ func (o *Parser) ParseFunc(receiver *NameTX) *FuncRecX {
 	return fn
 }
 
+// ParseGroup calls parseSpec once for a declaration like `const X = 1`,
+// or for each line of a group like `const ( X = 1 ; Y = 2 )`,
+// passing the index of the line, which is `iota` for consts.
+func (o *Parser) ParseGroup(parseSpec func(iota int)) {
+	if o.Word != "(" {
+		parseSpec(0)
+		return
+	}
+	o.Next()
+	iota := 0
+	for {
+		for o.Kind == L_EOL || o.Kind == L_Punc && o.Word == ";" {
+			o.Next()
+		}
+		if o.Word == ")" {
+			break
+		}
+		parseSpec(iota)
+		iota++
+		if o.Kind == L_Punc && o.Word == ";" {
+			o.Next()
+		} else if o.Word != ")" {
+			o.TakeEOL()
+		}
+	}
+	o.TakePunc(")")
+}
+
+func (o *Parser) ParseImportSpec() {
+	if o.Kind != L_String {
+		panic(F("after import, expected string, got %v", o.Word))
+	}
+	w := o.Word
+	o.Next()
+	gd := &GDef{
+		name:   w,
+		typeof: ImportTO,
+	}
+	o.Imports = append(o.Imports, gd)
+	o.ImportsMap[w] = gd
+}
+
+// ParseConstSpec parses one const.  In a group, if it has no `=`,
+// it repeats the type and expression of the previous one, prev.
+func (o *Parser) ParseConstSpec(iota int, prev *GDef) *GDef {
+	w := o.TakeIdent()
+	var tx, x Expr
+	if o.Kind == L_EOL || o.Word == ")" || o.Word == ";" {
+		if prev == nil {
+			panic(F("missing value in declaration of const %s", w))
+		}
+		tx, x = prev.typex, prev.initx
+	} else {
+		if o.Word != "=" {
+			tx = o.ParseType()
+		}
+		o.TakePunc("=")
+		x = o.ParseExpr()
+	}
+	gd := &GDef{
+		Package: o.Package,
+		name:    w,
+		initx:   x,
+		typex:   tx,
+		iota:    iota,
+	}
+	o.Consts = append(o.Consts, gd)
+	o.ConstsMap[w] = gd
+	return gd
+}
+
+func (o *Parser) ParseVarSpec() {
+	w := o.TakeIdent()
+	var tx Expr
+	if o.Word != "=" {
+		tx = o.ParseType()
+	}
+	var i Expr
+	if o.Word == "=" {
+		o.Next()
+		i = o.ParseExpr()
+	}
+	gd := &GDef{
+		Package: o.Package,
+		name:    w,
+		typex:   tx,
+		initx:   i,
+	}
+	o.Vars = append(o.Vars, gd)
+	o.VarsMap[w] = gd
+}
+
+func (o *Parser) ParseTypeSpec() {
+	w := o.TakeIdent()
+	var tx Expr
+	if o.Word == "interface" {
+		o.Next()
+		tx = &InterfaceTX{o.ParseInterfaceType(w)}
+	} else if o.Word == "struct" {
+		o.Next()
+		tx = &StructTX{o.ParseStructType(w)}
+	} else {
+		tx = o.ParseType()
+	}
+	gd := &GDef{
+		Package: o.Package,
+		name:    w,
+		initx:   tx,
+		typeof:  TypeTO,
+	}
+	o.Types = append(o.Types, gd)
+	o.TypesMap[w] = gd
+}
+
 func (o *Parser) ParseModule(cm *CMod, cg *CGen) {
 	o.CMod = cm
 LOOP:
@@ -614,74 +991,14 @@ LOOP:
 				}
 				o.Package = w
 			case "import":
-				if o.Kind != L_String {
-					panic(F("after import, expected string, got %v", o.Word))
-				}
-				w := o.Word
-				o.Next()
-				gd := &GDef{
-					name:   w,
-					typeof: ImportTO,
-				}
-				o.Imports = append(o.Imports, gd)
-				o.ImportsMap[w] = gd
+				o.ParseGroup(func(int) { o.ParseImportSpec() })
 			case "const":
-				w := o.TakeIdent()
-				var tx Expr
-				if o.Word != "=" {
-					tx = o.ParseType()
-				}
-				o.TakePunc("=")
-				x := o.ParseExpr()
-				gd := &GDef{
-					Package: o.Package,
-					name:    w,
-					initx:   x,
-					typex:   tx,
-				}
-				o.Consts = append(o.Consts, gd)
-				o.ConstsMap[w] = gd
+				var prev *GDef
+				o.ParseGroup(func(iota int) { prev = o.ParseConstSpec(iota, prev) })
 			case "var":
-				w := o.TakeIdent()
-				var tx Expr
-				if o.Word != "=" {
-					tx = o.ParseType()
-				}
-				var i Expr
-				if o.Word == "=" {
-					o.Next()
-					i = o.ParseExpr()
-				}
-				gd := &GDef{
-					Package: o.Package,
-					name:    w,
-					typex:   tx,
-					initx:   i,
-				}
-				o.Vars = append(o.Vars, gd)
-				o.VarsMap[w] = gd
+				o.ParseGroup(func(int) { o.ParseVarSpec() })
 			case "type":
-				w := o.TakeIdent()
-				var tx Expr
-				if o.Word == "interface" {
-					o.Next()
-					tx = &InterfaceTX{o.ParseInterfaceType(w)}
-				} else if o.Word == "struct" {
-					o.Next()
-					tx = &StructTX{o.ParseStructType(w)}
-				} else if o.Word == "func" {
-					panic("todo")
-				} else {
-					tx = o.ParseType()
-				}
-				gd := &GDef{
-					Package: o.Package,
-					name:    w,
-					initx:   tx,
-					typeof:  TypeTO,
-				}
-				o.Types = append(o.Types, gd)
-				o.TypesMap[w] = gd
+				o.ParseGroup(func(int) { o.ParseTypeSpec() })
 			case "func":
 				var receiver *NameTX
 				if o.Word == "(" {
@@ -695,10 +1012,15 @@ LOOP:
 						o.Next()
 					}
 
-					if o.Word != "*" {
-						panic(F("Got %q but expected '*': Method receiver type must be pointer to struct", o.Word))
+					var rType Expr
+					if o.Word == ")" {
+						// Only the receiver type was given.
+						rType = &IdentX{rName, o.CMod}
+						rName = "_"
+					} else {
+						// Pointer to struct, or a named type.
+						rType = o.ParseExpr()
 					}
-					rType := o.ParseExpr()
 					o.TakePunc(")")
 					receiver = &NameTX{rName, rType, o.CMod}
 				}
@@ -0,0 +1,229 @@
+#include "___.defs.h"
+
+// A Chan is a handle to a ChanObj in the GC Heap, with class C_Chan.
+// The buffer of a buffered Chan is a ring of elements that follows
+// the ChanObj in the same object.  Only the buffered elements are
+// live, so mark_chan() uses the ChanObj's eshape on just those.
+//
+// A goroutine that blocks on a Chan waits in its recvq or sendq,
+// with a Waiter that lives on its own C stack.
+// A goroutine blocked in Select has a Waiter on each Chan,
+// all pointing to one Selecting; the first to be dequeued
+// wins, and the rest go stale.
+
+typedef struct Selecting {
+  struct Waiter* fired;  // The Waiter that was dequeued, if any.
+} Selecting;
+
+typedef struct Waiter {
+  struct Waiter* next;
+  Goroutine* g;
+  void* data;  // Where to copy the element from (send) or to (recv).
+  bool ok;     // False if woken by close.
+  byte index;  // Which case, if sel.
+  Selecting* sel;
+} Waiter;
+
+typedef struct ChanObj {
+  const char* eshape;  // GC mark shape for an element (relative to element-1).
+  byte esize;
+  byte cap;
+  byte len;
+  byte head;  // Index of the oldest buffered element.
+  bool closed;
+  byte pad_;
+  Waiter* recvq;
+  Waiter* sendq;
+} ChanObj;
+
+#define CHAN_ELEM(C, I) ((char*)((C) + 1) + (word)(I) * (C)->esize)
+
+static void enqueue(Waiter** q, Waiter* w) {
+  w->next = NULL;
+  while (*q) q = &(*q)->next;
+  *q = w;
+}
+
+// peek drops stale Waiters, and returns the first live one.
+static Waiter* peek(Waiter** q) {
+  while (*q && (*q)->sel && (*q)->sel->fired) *q = (*q)->next;
+  return *q;
+}
+
+static Waiter* dequeue(Waiter** q) {
+  Waiter* w = peek(q);
+  if (w) {
+    *q = w->next;
+    if (w->sel) w->sel->fired = w;
+  }
+  return w;
+}
+
+static void unqueue(Waiter** q, Waiter* w) {
+  for (; *q; q = &(*q)->next) {
+    if (*q == w) {
+      *q = w->next;
+      return;
+    }
+  }
+}
+
+Chan MakeChan(const char* eshape, byte esize, int cap) {
+  if (cap < 0 || cap > 255) panic_s("makechan: size out of range");
+  word p = oalloc(CheckLen(sizeof(ChanObj) + cap * esize), C_Chan);
+  assert(p);
+  memset((char*)p, 0, sizeof(ChanObj));
+  ChanObj* c = (ChanObj*)p;
+  c->eshape = eshape;
+  c->esize = esize;
+  c->cap = (byte)cap;
+  return p;
+}
+
+void ChanSend(Chan h, void* value) {
+  if (!h) Park();  // A nil Chan blocks forever.
+  ChanObj* c = (ChanObj*)h;
+  if (c->closed) panic_s("send on closed channel");
+
+  Waiter* w = dequeue(&c->recvq);
+  if (w) {
+    memcpy(w->data, value, c->esize);
+    w->ok = true;
+    Ready(w->g);
+    return;
+  }
+  if (c->len < c->cap) {
+    memcpy(CHAN_ELEM(c, (c->head + c->len) % c->cap), value, c->esize);
+    c->len++;
+    return;
+  }
+
+  Waiter me = {NULL, CurrentG, value, false, 0, NULL};
+  enqueue(&c->sendq, &me);
+  Park();
+  if (!me.ok) panic_s("send on closed channel");
+}
+
+bool ChanRecv(Chan h, void* value) {
+  if (!h) Park();  // A nil Chan blocks forever.
+  ChanObj* c = (ChanObj*)h;
+
+  if (c->len) {
+    memcpy(value, CHAN_ELEM(c, c->head), c->esize);
+    c->head = (c->head + 1) % c->cap;
+    c->len--;
+    // Now there is room for a blocked sender.
+    Waiter* w = dequeue(&c->sendq);
+    if (w) {
+      memcpy(CHAN_ELEM(c, (c->head + c->len) % c->cap), w->data, c->esize);
+      c->len++;
+      w->ok = true;
+      Ready(w->g);
+    }
+    return true;
+  }
+  Waiter* w = dequeue(&c->sendq);
+  if (w) {
+    memcpy(value, w->data, c->esize);
+    w->ok = true;
+    Ready(w->g);
+    return true;
+  }
+  if (c->closed) {
+    memset(value, 0, c->esize);
+    return false;
+  }
+
+  Waiter me = {NULL, CurrentG, value, false, 0, NULL};
+  enqueue(&c->recvq, &me);
+  Park();
+  return me.ok;
+}
+
+void ChanClose(Chan h) {
+  if (!h) panic_s("close of nil channel");
+  ChanObj* c = (ChanObj*)h;
+  if (c->closed) panic_s("close of closed channel");
+  c->closed = true;
+
+  Waiter* w;
+  while ((w = dequeue(&c->recvq))) {
+    memset(w->data, 0, c->esize);
+    Ready(w->g);  // ok is false.
+  }
+  while ((w = dequeue(&c->sendq))) {
+    Ready(w->g);  // ok is false, so it panics.
+  }
+}
+
+P_int ChanLen(Chan h) {
+  if (!h) return 0;
+  return ((ChanObj*)h)->len;
+}
+
+static bool select_ready(SelectCase* sc) {
+  ChanObj* c = (ChanObj*)sc->c;
+  if (!c) return false;  // A nil Chan is never ready.
+  if (sc->send) return c->closed || peek(&c->recvq) || c->len < c->cap;
+  return c->len || peek(&c->sendq) || c->closed;
+}
+
+// Select does one of the cases that is ready, and returns its index.
+// If none is ready, it returns -1 if there is a default,
+// or else waits on all the cases.
+P_int Select(SelectCase* cases, P_int n, bool hasDefault) {
+  // Start polling at a different case each time, for a little fairness.
+  static byte rotor;
+  rotor++;
+  for (P_int k = 0; k < n; k++) {
+    P_int i = (k + rotor) % n;
+    SelectCase* sc = cases + i;
+    if (select_ready(sc)) {
+      if (sc->send) {
+        ChanSend(sc->c, sc->data);
+      } else {
+        sc->ok = ChanRecv(sc->c, sc->data);
+      }
+      return i;
+    }
+  }
+  if (hasDefault) return -1;
+
+  if (n > SELECT_MAX) panic_s("select: too many cases");
+  Selecting sel = {NULL};
+  Waiter ws[SELECT_MAX];
+  for (P_int i = 0; i < n; i++) {
+    Waiter* w = ws + i;
+    w->g = CurrentG;
+    w->data = cases[i].data;
+    w->ok = false;
+    w->index = (byte)i;
+    w->sel = &sel;
+    ChanObj* c = (ChanObj*)cases[i].c;
+    if (c) enqueue(cases[i].send ? &c->sendq : &c->recvq, w);
+  }
+  Park();
+
+  // The Waiters are on this stack, so none may stay queued.
+  for (P_int i = 0; i < n; i++) {
+    ChanObj* c = (ChanObj*)cases[i].c;
+    if (c) unqueue(cases[i].send ? &c->sendq : &c->recvq, ws + i);
+  }
+  Waiter* w = sel.fired;
+  assert(w);
+  SelectCase* sc = cases + w->index;
+  if (sc->send && !w->ok) panic_s("send on closed channel");
+  sc->ok = w->ok;
+  return w->index;
+}
+
+#ifndef unix
+extern void mark_with_shape(const char* s, word h);
+
+void mark_chan(word h) {
+  ChanObj* c = (ChanObj*)h;
+  for (byte i = 0; i < c->len; i++) {
+    mark_with_shape(c->eshape, (word)CHAN_ELEM(c, (c->head + i) % c->cap) - 1);
+  }
+}
+#endif
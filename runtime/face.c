@@ -0,0 +1,82 @@
+#include "___.defs.h"
+
+// Runtime type tests for interfaces.  All pointers to structs
+// have the same typecode "PR0", and a non-empty interface is
+// just the handle, so both are told apart by their heap class.
+// A defined type has the typecode of its representation
+// followed by "@cname;", so it differs from its underlying type.
+
+byte HandleClass(word h) {
+  return h ? ocls(h) : 0;
+}
+
+byte AnyClass(Any a) {
+  if (!a.typecode) return 0;
+  if (strcmp(a.typecode, "PR0") && strcmp(a.typecode, "I0")) return 0;
+  return HandleClass(*(word*)a.pointer);
+}
+
+static char NameBuf[40];
+
+static const char* TypeCodeName(const char* typecode) {
+  if (!typecode) return "nil";
+  if (typecode[0] && typecode[1] == '@') {
+    // A defined type over a basic type, like "i@main__Celsius;".
+    byte n = 0;
+    for (const char* p = typecode + 2; *p && *p != ';' && n < sizeof NameBuf - 1; p++) {
+      NameBuf[n++] = *p;
+    }
+    NameBuf[n] = 0;
+    return NameBuf;
+  }
+  if (typecode[0] && !typecode[1]) {
+    switch (typecode[0]) {
+      case 'z':
+        return "bool";
+      case 'b':
+        return "byte";
+      case 'i':
+        return "int";
+      case 'u':
+        return "uint";
+      case 'p':
+        return "uintptr";
+      case 's':
+        return "string";
+    }
+  }
+  return typecode;
+}
+
+static char AssertBuf[100];
+
+static void AssertAppend(const char* s) {
+  int n = strlen(AssertBuf);
+  strncpy(AssertBuf + n, s, sizeof AssertBuf - 1 - n);
+}
+
+// PanicAssert panics like Go for a failed type assertion,
+// where have is the dynamic type and want the asserted type.
+static void PanicAssert(const char* have, byte cls, const char* want) {
+  AssertBuf[0] = 0;
+  AssertAppend("interface conversion: interface is ");
+  if (cls) {
+    AssertAppend("*");
+    AssertAppend(ClassNames[cls]);
+  } else {
+    AssertAppend(have);
+  }
+  AssertAppend(", not ");
+  AssertAppend(want);
+  String s = MakeStringFromC(AssertBuf);
+  Any a = {&s, "s"};
+  Panic(a);
+}
+
+void PanicAssertAny(Any a, const char* want) {
+  PanicAssert(TypeCodeName(a.typecode), AnyClass(a), want);
+}
+
+void PanicAssertHandle(word h, const char* want) {
+  PanicAssert("nil", HandleClass(h), want);
+}
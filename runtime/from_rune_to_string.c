@@ -0,0 +1,31 @@
+#include "___.defs.h"
+
+// FromRuneToString encodes rune r in UTF-8.
+// Runes are limited to 16 bits, so bad ones,
+// and those beyond, become U+FFFD.
+// It is compared unsigned, since with a 16-bit int,
+// U+8000 to U+FFFF look negative.
+String FromRuneToString(P_int r) {
+    P_uint u = (P_uint)r;
+    P_uint c = 0xFFFD;
+    if (u < 0xD800 || (0xDFFF < u && u <= 0xFFFF)) {
+        c = u;
+    }
+
+    byte buf[3];
+    int n;
+    if (c < 0x80) {
+        buf[0] = (byte)c;
+        n = 1;
+    } else if (c < 0x800) {
+        buf[0] = (byte)(0xC0 | (c >> 6));
+        buf[1] = (byte)(0x80 | (c & 0x3F));
+        n = 2;
+    } else {
+        buf[0] = (byte)(0xE0 | (c >> 12));
+        buf[1] = (byte)(0x80 | ((c >> 6) & 0x3F));
+        buf[2] = (byte)(0x80 | (c & 0x3F));
+        n = 3;
+    }
+    return MakeString((const char*)buf, n);
+}
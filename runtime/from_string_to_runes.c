@@ -0,0 +1,54 @@
+#include "___.defs.h"
+
+// DecodeRune decodes the UTF-8 rune at p, with n bytes left,
+// and sets *size to the number of bytes it used.
+// A bad encoding gives U+FFFD and uses one byte.
+// A rune beyond 16 bits also gives U+FFFD, but uses all its bytes.
+static P_uint DecodeRune(const byte* p, int n, int* size) {
+    byte c = p[0];
+    *size = 1;
+    if (c < 0x80) return c;
+
+    int more;
+    P_uint r;
+    if ((c & 0xE0) == 0xC0) {
+        more = 1;
+        r = c & 0x1F;
+    } else if ((c & 0xF0) == 0xE0) {
+        more = 2;
+        r = c & 0x0F;
+    } else if ((c & 0xF8) == 0xF0) {
+        more = 3;
+        r = 0;
+    } else {
+        return 0xFFFD;
+    }
+    if (more >= n) return 0xFFFD;
+    for (int i = 1; i <= more; i++) {
+        if ((p[i] & 0xC0) != 0x80) return 0xFFFD;
+        r = (r << 6) | (p[i] & 0x3F);
+    }
+    if (more == 1 && r < 0x80) return 0xFFFD;   // overlong
+    if (more == 2 && (r < 0x800 || (0xD800 <= r && r <= 0xDFFF))) return 0xFFFD;
+    *size = more + 1;
+    if (more == 3) return 0xFFFD;  // beyond 16 bits
+    return r;
+}
+
+// FromStringToRunes decodes the UTF-8 in a into a new slice of runes.
+Slice FromStringToRunes(String a) {
+    const byte* p = (const byte*)STRING_START(a);
+    int count = 0;
+    int size;
+    for (int i = 0; i < a.len; i += size) {
+        DecodeRune(p + i, a.len - i, &size);
+        count++;
+    }
+
+    Slice z = MakeSlice("i", count, count, sizeof(P_int));
+    P_int* dest = (P_int*)(z.base + z.offset);
+    for (int i = 0; i < a.len; i += size) {
+        *dest++ = (P_int)DecodeRune(p + i, a.len - i, &size);
+    }
+    return z;
+}
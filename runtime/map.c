@@ -0,0 +1,174 @@
+#include "___.defs.h"
+
+// A Map is a handle to a MapObj in the GC Heap, with class C_Map.
+// Each bucket is a chain of entries.  Each entry is a separate
+// C_Bytes object laid out as {word next; P_uint seq; K key; V value},
+// so the GC cannot trace an entry by its class alone:
+// mark_map() walks the chains and uses the MapObj's eshape.
+// New entries go on the front of a chain with a higher seq,
+// so seqs decrease along a chain, and MapNext can find its
+// place again by seq after entries are deleted or added.
+
+#define MAP_BUCKETS 16
+
+typedef struct MapObj {
+  const char* eshape;  // GC mark shape for an entry (relative to entry-1).
+  byte kind;           // 's' for string keys, else keys compare as bytes.
+  byte ksize;
+  byte vsize;
+  byte pad_;
+  P_uint len;  // number of entries.
+  P_uint seq;  // seq of the newest entry.
+  word buckets[MAP_BUCKETS];
+} MapObj;
+
+#define ENTRY_NEXT(E) (*(word*)(E))
+#define ENTRY_SEQ(E) (*(P_uint*)((char*)(E) + sizeof(word)))
+#define ENTRY_KEY(E) ((char*)(E) + sizeof(word) + sizeof(P_uint))
+#define ENTRY_VALUE(M, E) (ENTRY_KEY(E) + (M)->ksize)
+
+Map MakeMap(const char* eshape, byte kind, byte ksize, byte vsize) {
+  word p = oalloc(CheckLen(sizeof(MapObj)), C_Map);
+  assert(p);
+  memset((char*)p, 0, sizeof(MapObj));
+  MapObj* m = (MapObj*)p;
+  m->eshape = eshape;
+  m->kind = kind;
+  m->ksize = ksize;
+  m->vsize = vsize;
+  return p;
+}
+
+static byte map_hash(MapObj* m, void* key) {
+  const byte* p;
+  P_uint n;
+  if (m->kind == 's') {
+    String* s = (String*)key;
+    p = (const byte*)STRING_START(*s);
+    n = s->len;
+  } else {
+    p = (const byte*)key;
+    n = m->ksize;
+  }
+  byte h = 0;
+  for (P_uint i = 0; i < n; i++) {
+    h = (byte)((h << 1) + (h >> 7) + p[i]);
+  }
+  return h & (MAP_BUCKETS - 1);
+}
+
+static bool map_key_eq(MapObj* m, void* a, void* b) {
+  if (m->kind == 's') {
+    String* x = (String*)a;
+    String* y = (String*)b;
+    if (x->len != y->len) return false;
+    return !memcmp(STRING_START(*x), STRING_START(*y), x->len);
+  }
+  return !memcmp(a, b, m->ksize);
+}
+
+// map_find returns the entry for key, or 0.
+static word map_find(MapObj* m, void* key) {
+  word e = m->buckets[map_hash(m, key)];
+  for (; e; e = ENTRY_NEXT(e)) {
+    if (map_key_eq(m, ENTRY_KEY(e), key)) return e;
+  }
+  return 0;
+}
+
+bool MapGet(Map a, void* key, void* value, int vsize) {
+  if (a) {
+    MapObj* m = (MapObj*)a;
+    word e = map_find(m, key);
+    if (e) {
+      memcpy(value, ENTRY_VALUE(m, e), m->vsize);
+      return true;
+    }
+  }
+  memset(value, 0, vsize);
+  return false;
+}
+
+// map_renumber gives the entries of each chain new seqs 1, 2, ...
+// from the tail, when the seq counter is about to wrap around.
+// An iteration in progress may then repeat some entries.
+static void map_renumber(MapObj* m) {
+  m->seq = 0;
+  for (byte b = 0; b < MAP_BUCKETS; b++) {
+    P_uint n = 0;
+    for (word e = m->buckets[b]; e; e = ENTRY_NEXT(e)) n++;
+    if (n > m->seq) m->seq = n;
+    for (word e = m->buckets[b]; e; e = ENTRY_NEXT(e)) ENTRY_SEQ(e) = n--;
+  }
+}
+
+void MapPut(Map a, void* key, void* value) {
+  if (!a) panic_s("assignment to entry in nil map");
+  MapObj* m = (MapObj*)a;
+  word e = map_find(m, key);
+  if (!e) {
+    byte b = map_hash(m, key);
+    e = oalloc(CheckLen(sizeof(word) + sizeof(P_uint) + m->ksize + m->vsize), C_Bytes);
+    assert(e);
+    if (m->seq == (P_uint)~0) map_renumber(m);
+    ENTRY_SEQ(e) = ++m->seq;
+    memcpy(ENTRY_KEY(e), key, m->ksize);
+    ENTRY_NEXT(e) = m->buckets[b];
+    m->buckets[b] = e;
+    m->len++;
+  }
+  memcpy(ENTRY_VALUE(m, e), value, m->vsize);
+}
+
+void MapDelete(Map a, void* key) {
+  if (!a) return;
+  MapObj* m = (MapObj*)a;
+  word* pp = &m->buckets[map_hash(m, key)];
+  for (; *pp; pp = (word*)*pp) {
+    if (map_key_eq(m, ENTRY_KEY(*pp), key)) {
+      *pp = ENTRY_NEXT(*pp);
+      m->len--;
+      return;
+    }
+  }
+}
+
+P_int MapLen(Map a) {
+  if (!a) return 0;
+  return ((MapObj*)a)->len;
+}
+
+// MapNext advances the cursor, which starts at {0, 0},
+// and copies out the next key and value (either may be NULL).
+// The cursor holds the bucket and the seq of the entry last
+// returned from it (0 for none yet), rather than a position
+// in the chain, so deleting entries while ranging skips none.
+bool MapNext(Map a, P_uint* bucket, P_uint* seq, void* key, void* value) {
+  if (!a) return false;
+  MapObj* m = (MapObj*)a;
+  for (; *bucket < MAP_BUCKETS; (*bucket)++, *seq = 0) {
+    word e = m->buckets[*bucket];
+    while (e && *seq && ENTRY_SEQ(e) >= *seq) e = ENTRY_NEXT(e);
+    if (e) {
+      if (key) memcpy(key, ENTRY_KEY(e), m->ksize);
+      if (value) memcpy(value, ENTRY_VALUE(m, e), m->vsize);
+      *seq = ENTRY_SEQ(e);
+      return true;
+    }
+  }
+  return false;
+}
+
+#ifndef unix
+extern void mark_with_shape(const char* s, word h);
+
+void mark_map(word h) {
+  MapObj* m = (MapObj*)h;
+  for (byte b = 0; b < MAP_BUCKETS; b++) {
+    for (word e = m->buckets[b]; e; e = ENTRY_NEXT(e)) {
+      omark(e);
+      mark_with_shape(m->eshape, e - 1);
+    }
+  }
+}
+#endif
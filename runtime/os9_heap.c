@@ -0,0 +1,137 @@
+#include "___.defs.h"
+
+// A first-fit GC Heap, with objects laid out one after another
+// from begin to end.  When oalloc finds no room, ogc marks what
+// is reachable (by the omarker from oinit), frees the rest,
+// merges free neighbors, and oalloc tries again.
+
+static word HeapBegin;
+static word HeapEnd;
+static omarker Marker;
+
+#define HEADER(A) ((OHeader*)(A) - 1)
+#define NEXT(H) ((OHeader*)((char*)((H) + 1) + (H)->cap))
+
+void oinit(word begin, word end, omarker fn) {
+  HeapBegin = (begin + 1) & ~(word)1;
+  HeapEnd = end & ~(word)1;
+  Marker = fn;
+  OHeader* h = (OHeader*)HeapBegin;
+  h->cap = HeapEnd - HeapBegin - sizeof(OHeader);
+  h->cls = C_Free;
+  h->marked = false;
+}
+
+// ofind returns the first free object with room for cap bytes,
+// after splitting any extra room off into another free object.
+static OHeader* ofind(word cap) {
+  for (OHeader* h = (OHeader*)HeapBegin; (word)h < HeapEnd; h = NEXT(h)) {
+    if (h->cls != C_Free || h->cap < cap) continue;
+    if (h->cap >= cap + sizeof(OHeader) + 2) {
+      OHeader* rest = (OHeader*)((char*)(h + 1) + cap);
+      rest->cap = h->cap - cap - sizeof(OHeader);
+      rest->cls = C_Free;
+      rest->marked = false;
+      h->cap = cap;
+    }
+    return h;
+  }
+  return NULL;
+}
+
+word oalloc(word len, byte cls) {
+  assert(len <= MAX_OBJ_LEN);
+  assert(cls != C_Free);
+  word cap = (len < 2) ? 2 : (len + 1) & ~(word)1;
+  OHeader* h = ofind(cap);
+  if (!h) {
+    ogc();
+    h = ofind(cap);
+  }
+  if (!h) {
+    fprintf(stderr, "\nfatal error: out of memory\n");
+    exit(2);
+  }
+  h->cls = cls;
+  h->marked = false;
+  ozero((word)(h + 1), h->cap);
+  return (word)(h + 1);
+}
+
+// ogc frees every object that the Marker does not mark.
+void ogc() {
+  if (Marker) Marker();
+  OHeader* prev = NULL;  // A free object just before h.
+  for (OHeader* h = (OHeader*)HeapBegin; (word)h < HeapEnd; h = NEXT(h)) {
+    if (!h->marked) h->cls = C_Free;
+    h->marked = false;
+    if (h->cls != C_Free) {
+      prev = NULL;
+    } else if (prev) {
+      prev->cap += sizeof(OHeader) + h->cap;
+      h = prev;
+    } else {
+      prev = h;
+    }
+  }
+}
+
+bool omark(word addr) {
+  if (!ovalidaddr(addr)) return false;
+  OHeader* h = HEADER(addr);
+  if (h->marked) return false;
+  h->marked = true;
+  return true;
+}
+
+void ozero(word begin, word len) { memset((char*)begin, 0, len); }
+
+void ofree(word addr) {
+  assert(ovalidaddr(addr));
+  HEADER(addr)->cls = C_Free;
+}
+
+// ovalidaddr tells if addr is in the heap, and not free.
+// It does not check that addr is the start of an object.
+bool ovalidaddr(word addr) {
+  if (addr & 1) return false;
+  if (addr < HeapBegin + sizeof(OHeader)) return false;
+  if (addr >= HeapEnd) return false;
+  return HEADER(addr)->cls != C_Free;
+}
+
+word ocap(word addr) {
+  assert(ovalidaddr(addr));
+  return HEADER(addr)->cap;
+}
+
+byte ocls(word addr) {
+  assert(ovalidaddr(addr));
+  return HEADER(addr)->cls;
+}
+
+void osay(word addr) {
+  memset(Buffer2, 0, sizeof(Buffer2));
+  P2 = Buffer2;
+  PutS2(" [[cls=");
+  PutX2(ocls(addr));
+  PutS2(" cap=");
+  PutX2(ocap(addr));
+  PutS2("]]\n");
+  Write2();
+}
+
+void omemcpy(word d, word s, word n) { memcpy((char*)d, (char*)s, n); }
+
+// omemcmp compares two runs of bytes like memcmp,
+// with a shorter prefix coming first.
+int omemcmp(word pchar1, word len1, word pchar2, word len2) {
+  const byte* p = (const byte*)pchar1;
+  const byte* q = (const byte*)pchar2;
+  word n = (len1 < len2) ? len1 : len2;
+  for (word i = 0; i < n; i++) {
+    if (p[i] != q[i]) return (p[i] < q[i]) ? -1 : 1;
+  }
+  if (len1 == len2) return 0;
+  return (len1 < len2) ? -1 : 1;
+}
@@ -0,0 +1,32 @@
+#ifndef GOSUB_OS9_HEAP_H_
+#define GOSUB_OS9_HEAP_H_
+
+// The GC Heap on OS-9.  Each object follows an OHeader
+// with its capacity as a word, so an object can be bigger
+// than 255 bytes.  Free space is objects of class C_Free.
+
+typedef void (*omarker)();
+
+// MAX_OBJ_LEN is the largest length oalloc can give.
+#define MAX_OBJ_LEN 0x7FFE
+
+typedef struct OHeader {
+  word cap;  // capacity in bytes, after the header; even.
+  byte cls;  // C_Free if free.
+  bool marked;
+} OHeader;
+
+void oinit(word begin, word end, omarker fn);
+word oalloc(word len, byte cls);
+void ogc();
+bool omark(word addr);  // true if it was not marked yet.
+void ozero(word begin, word len);
+void ofree(word addr);  // Unsafe.
+bool ovalidaddr(word addr);
+word ocap(word addr);  // capacity in bytes.
+byte ocls(word addr);
+void osay(word addr);
+void omemcpy(word d, word s, word n);
+int omemcmp(word pchar1, word len1, word pchar2, word len2);
+
+#endif
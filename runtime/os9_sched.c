@@ -0,0 +1,60 @@
+#include "___.defs.h"
+
+// On OS-9, goroutine stacks come from a fixed pool,
+// and switching goroutines just switches the S and U registers.
+// Y is the same for everyone, and cmoc saves nothing else
+// across calls.
+
+#ifndef GO_MAX
+#define GO_MAX 6
+#endif
+#ifndef GO_STACK_SIZE
+#define GO_STACK_SIZE 1024
+#endif
+
+typedef struct Os9G {
+  Goroutine g;
+  word sp;  // saved S, while switched out.
+  byte stack[GO_STACK_SIZE];
+} Os9G;
+
+static Os9G Pool[GO_MAX];
+static word MainSP;
+
+// A new stack looks like SwitchStacks() was called on it,
+// with a fake frame (U) whose return address is GoStart().
+Goroutine* NewG() {
+  for (byte i = 0; i < GO_MAX; i++) {
+    Os9G* o = Pool + i;
+    if (o->g.ctx) continue;  // in use.
+
+    memset(&o->g, 0, sizeof o->g);
+    word* top = (word*)(o->stack + GO_STACK_SIZE);
+    top[-1] = (word)GoStart;    // PC pulled by the epilogue.
+    top[-2] = 0;                // U pulled by the epilogue.
+    top[-3] = (word)(top - 2);  // U pulled in SwitchStacks.
+    o->sp = (word)(top - 3);
+    o->g.ctx = &o->sp;
+    return &o->g;
+  }
+  return NULL;
+}
+
+void FreeG(Goroutine* g) { g->ctx = NULL; }
+
+// After switching S, the cmoc epilogue `leas ,u; puls u,pc`
+// returns from the SwitchStacks() call on the other stack.
+static void SwitchStacks(word* save, word newsp) {
+  asm {
+    pshs u
+    ldx save
+    sts ,x
+    lds newsp
+    puls u
+  }
+}
+
+void SwitchG(Goroutine* from, Goroutine* to) {
+  if (!from->ctx) from->ctx = &MainSP;  // main's stack.
+  SwitchStacks((word*)from->ctx, *(word*)to->ctx);
+}
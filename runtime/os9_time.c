@@ -0,0 +1,22 @@
+#include "___.defs.h"
+#include "os9.h"
+
+// OS-9 has no cheap clock finer than seconds, so this clock
+// only advances while sleeping, which is when all goroutines
+// are blocked.  F$Sleep counts ticks, at 60 per second.
+
+static word Clock;
+
+word Millis() { return Clock; }
+
+void SleepMillis(word ms) {
+  word ticks = (word)(((unsigned long)ms * 60 + 999) / 1000);
+  asm {
+    ldx ticks
+    pshs y,u
+    swi2
+    fcb F_SLEEP
+    puls y,u
+  }
+  Clock += ms;
+}
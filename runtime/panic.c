@@ -0,0 +1,99 @@
+#include "___.defs.h"
+
+// Panics unwind to the nearest Catcher, which is installed
+// by the first `defer` in a func.  The func then runs its
+// deferred calls, and either returns (if one of them called
+// recover()) or continues unwinding to the next Catcher.
+
+Catcher* CurrentCatcher;
+
+// DeferringFrame is the Frame of the func whose deferred
+// call is running.  As in Go, recover() only stops a panic
+// when the deferred func itself calls it, that is, when
+// the caller's Frame came right after DeferringFrame.
+struct Frame* DeferringFrame;
+
+bool PanicActive;
+Any Panicking;
+
+// The panic value is copied here, because the frame it
+// was in will be gone.  It is big enough for any value.
+static union {
+  String s;
+  Slice sl;
+  Any a;
+  Func f;
+  word w;
+} PanicBuf;
+
+static int TypeCodeSize(const char* typecode) {
+  switch (typecode[0]) {
+    case 'z':
+    case 'b':
+      return 1;
+    case 'i':
+    case 'u':
+    case 'k':
+      return sizeof(P_int);
+    case 's':
+      return sizeof(String);
+    case 'S':
+      return sizeof(Slice);
+    case 'a':
+      return sizeof(Any);
+    case 'F':
+      return sizeof(Func);
+  }
+  return sizeof(word);  // handles and pointers.
+}
+
+static void PanicDie() {
+  Slice args = {(word)&Panicking, 0, sizeof(Panicking)};
+  low__FormatToBuffer(MakeStringFromC("\nPANIC: %v\n"), args);
+  P_int count, err;
+  low__WriteBuffer(2, &count, &err);
+  exit(63);
+}
+
+void Panic(Any a) {
+  if (a.pointer != (void*)&PanicBuf) {
+    memcpy(&PanicBuf, a.pointer, TypeCodeSize(a.typecode));
+  }
+  Panicking.pointer = &PanicBuf;
+  Panicking.typecode = a.typecode;
+  PanicActive = true;
+  Repanic();
+}
+
+void Repanic() {
+  if (!PanicActive) return;
+  if (!CurrentCatcher) PanicDie();
+  longjmp(CurrentCatcher->jb, 1);
+}
+
+P__any_ builtin__recover() {
+  Any z = {0, 0};
+  if (!PanicActive) return z;
+  if (!DeferringFrame || CurrentFrame->fr_prev != DeferringFrame) return z;
+  PanicActive = false;
+  return Panicking;
+}
+
+#ifndef unix
+extern void mark_handle(word h);
+
+void mark_panicking() {
+  if (!PanicActive) return;
+  switch (Panicking.typecode[0]) {
+    case 's':
+    case 'S':
+    case 'P':
+    case 'I':
+    case 'M':
+      mark_handle(PanicBuf.w);
+      break;
+    case 'F':
+      mark_handle((word)PanicBuf.f.env);  // If it is in the heap.
+  }
+}
+#endif
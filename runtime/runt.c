@@ -13,6 +13,8 @@ byte Heap[25000];
 
 #ifndef unix
 void mark_handle(word h);
+void mark_map(word h);
+void mark_chan(word h);
 
 void mark_with_shape(const char* s, word h) {
   if (!s) return;
@@ -24,7 +26,7 @@ void mark_with_shape(const char* s, word h) {
 }
 
 void mark_handle(word h) {
-  if (!h) return;
+  if (!omark(h)) return;  // Nil, not in the heap, or marked already.
   byte cls = ocls(h);
   assert(cls < NUM_CLASSES);
   {
@@ -35,56 +37,92 @@ void mark_handle(word h) {
     PutS2(ClassNames[cls]);
     PutS2("} ");
   }
-  omark(h);
+  if (cls == C_Map) {
+    mark_map(h);  // Entries are not traceable by class.
+    return;
+  }
+  if (cls == C_Chan) {
+    mark_chan(h);  // Only the buffered elements are live.
+    return;
+  }
   mark_with_shape(ClassMarks[cls], h-1); // because first mark is relative to handle addr less one.
 }
 #endif
 
-extern void markvars();
-void mark_all() {
 #ifndef unix
-  // Mark global vars.
-  markvars();
-  
-  // Mark stack.
-  for (struct Frame* fr = CurrentFrame; fr; fr=fr->fr_prev) {
+void mark_frames(struct Frame* fr) {
+  for (; fr; fr=fr->fr_prev) {
     word h = (word)fr;
     for (const byte* s = (const byte*)fr->fr_shape; *s; s++) {
       h += (*s);
       mark_handle(*(word*)h);
     }
   }
+}
+#endif
+
+extern void markvars();
+extern void mark_panicking();
+extern void mark_goroutines();
+extern void mark_timers();
+void mark_all() {
+#ifndef unix
+  // Mark global vars.
+  markvars();
+  mark_panicking();
+  
+  // Mark stack, and the stacks of other goroutines.
+  mark_frames(CurrentFrame);
+  mark_goroutines();
+  mark_timers();
 #endif
 }
 
 void panic_s(const char* why) {
+  if (CurrentCatcher) {
+    // Let a deferred recover() see it.
+    String s = MakeStringFromC(why);
+    Any a = {&s, "s"};
+    Panic(a);
+  }
   fprintf(stderr, "\nPANIC: %s\n", why);
   assert(0);
 }
 
 Slice NilSlice = {0, 0, 0};
 
-byte CheckLen(int i) {
-  assert (i>=0);
-  assert (i<INF);
-  return (byte)i;
+word CheckLen(int i) {
+  if (i < 0 || i > MAX_OBJ_LEN) panic_s("object too large for heap");
+  return (word)i;
 }
 
-String MakeStringFromC(const char* s) {
-  int n = strlen(s);
-  if (n >= INF - 1) panic_s("MakeStringFromC: too long");
-  word p = oalloc(CheckLen(n + 1), 1);
-  assert(p);
-  strcpy((char*)p, s);
-  String z = {p, 0, n};
+// MakeString copies n bytes from p into a new string.
+// The bytes may include zeros; no terminator is added.
+String MakeString(const char* p, int n) {
+  String z = {0, 0, 0};
+  if (n <= 0) return z;
+  z.base = oalloc(CheckLen(n), C_Bytes);
+  assert(z.base);
+  memcpy((char*)z.base, p, n);
+  z.len = n;
   return z;
 }
+
+String MakeStringFromC(const char* s) {
+  return MakeString(s, strlen(s));
+}
+
+// MakeCStrFromString copies s into a new NUL-terminated C string,
+// for passing to the OS.  It cannot hold a zero byte of s.
 char* MakeCStrFromString(String s) {
   int n = s.len;
-  assert(n < 254);
   char* p = (char*) oalloc(CheckLen(n+1), C_Bytes);
   assert(p);
   memcpy(p, STRING_START(s), n);
+  p[n] = 0;
+  for (int i = 0; i < n; i++) {
+    if (!p[i]) panic_s("string with zero byte passed to OS");
+  }
   return p;
 }
 
@@ -96,16 +134,32 @@ void StringGet(String a, int nth, P_byte* out) {
 }
 
 String StringAdd(String a, String b) {
+  if (!a.len) return b;
+  if (!b.len) return a;
   int n = a.len + b.len;
-  if (n >= INF - 1) panic_s("MakeStringFromC: too long");
-  word p = oalloc(CheckLen(n + 1), 1);
+  word p = oalloc(CheckLen(n), C_Bytes);
   assert(p);
-  strcpy((char*)p, STRING_START(a));
-  strcpy((char*)p+a.len, STRING_START(b));
+  memcpy((char*)p, STRING_START(a), a.len);
+  memcpy((char*)p + a.len, STRING_START(b), b.len);
   String z = {p, 0, n};
   return z;
 }
 
+// StringCompare compares a and b byte-wise, as unsigned bytes,
+// returning negative, zero, or positive, like memcmp.
+int StringCompare(String a, String b) {
+  return omemcmp((word)STRING_START(a), a.len, (word)STRING_START(b), b.len);
+}
+
+bool StringEQ(String a, String b) {
+  return a.len == b.len && StringCompare(a, b) == 0;
+}
+bool StringNE(String a, String b) { return !StringEQ(a, b); }
+bool StringLT(String a, String b) { return StringCompare(a, b) < 0; }
+bool StringLE(String a, String b) { return StringCompare(a, b) <= 0; }
+bool StringGT(String a, String b) { return StringCompare(a, b) > 0; }
+bool StringGE(String a, String b) { return StringCompare(a, b) >= 0; }
+
 Slice MakeSlice(const char* typecode, int len, int cap, int size) {
   // cap is ignored.
   if (!len) {
@@ -122,27 +176,34 @@ Slice MakeSlice(const char* typecode, int len, int cap, int size) {
 }
 
 #define INITIAL_CAP 100
-#define MAX_CAP 254
+
+// SliceRoom is how many bytes from its base a slice may fill in place.
+// A slice of an array in a struct field (whose base has the
+// struct's class) does not know where the array ends, so it has
+// no room to grow, and append copies it.
+static word SliceRoom(Slice a) {
+  if (!a.base) return 0;
+  if (ocls(a.base) > C_Chan) return a.offset + a.len;
+  return ocap(a.base);
+}
+
+// GrowSlice copies the bytes of a into a new object of class cls,
+// with room for at least `more` more bytes.
+static Slice GrowSlice(Slice a, int more, byte cls) {
+  int need = a.len + more;
+  int cap = (need < INITIAL_CAP) ? INITIAL_CAP : need;
+  if (cap <= MAX_OBJ_LEN / 2) cap *= 2;
+  word p = oalloc(CheckLen(cap), cls);
+  assert(p);
+  if (a.base) omemcpy(p, a.base + a.offset, a.len);
+  Slice z = {p, 0, a.len};
+  return z;
+}
 
 Slice AppendSliceInt(Slice a, P_int x) {
-  if (!a.base) {
-    // Initial allocation.
-    word p = oalloc(INITIAL_CAP, 1);
-    assert(p);
-    a.base = p;
-    a.offset = 0;
-    a.len = 0;
-  }
-  byte cap = ocap(a.base);
-  if (a.offset + a.len + sizeof(P_int) > cap) {
-    assert(cap < MAX_CAP);
-
-    word p = oalloc(MAX_CAP, 1);
-    assert(p);
-    omemcpy(p, a.base, cap);
-    a.base = p;
+  if (a.offset + a.len + sizeof(P_int) > SliceRoom(a)) {
+    a = GrowSlice(a, sizeof(P_int), 1);
   }
-  assert(a.offset + a.len + sizeof(P_int) <= cap);
   *(P_int*)(a.base + a.offset + a.len) = x;
   a.len += sizeof(P_int);
   return a;
@@ -150,24 +211,9 @@ Slice AppendSliceInt(Slice a, P_int x) {
 
 Slice SliceAppend(Slice a, void* new_elem_ptr, int new_elem_size,
                   byte base_cls) {
-  if (!a.base) {
-    // Initial allocation.
-    word p = oalloc(INITIAL_CAP, base_cls);
-    assert(p);
-    a.base = p;
-    a.offset = 0;
-    a.len = 0;
+  if (a.offset + a.len + new_elem_size > SliceRoom(a)) {
+    a = GrowSlice(a, new_elem_size, base_cls);
   }
-  byte cap = ocap(a.base);
-  if (a.offset + a.len + new_elem_size > cap) {
-    assert(cap < MAX_CAP);
-
-    word p = oalloc(MAX_CAP, 1);
-    assert(p);
-    omemcpy(p, a.base, cap);
-    a.base = p;
-  }
-  assert(a.offset + a.len + new_elem_size <= cap);
   memcpy((char*)a.base + a.offset + a.len, new_elem_ptr, new_elem_size);
   a.len += new_elem_size;
   return a;
@@ -189,6 +235,19 @@ int SliceLen(Slice a, int size) {
   return a.len / size;
 }
 
+// CheckIndex returns the index i, if it is in range
+// for an array of length n.
+P_int CheckIndex(P_int i, P_int n) {
+  if (i < 0 || i >= n) panic_s("array index out of range");
+  return i;
+}
+
+// CheckSlice panics unless 0 <= a <= b <= n,
+// for slicing an array of length n like `p.buf[a:b]`.
+void CheckSlice(P_int a, P_int b, P_int n) {
+  if (a < 0 || a > b || b > n) panic_s("slice bounds out of range");
+}
+
 void builtin__println(Slice args) {
   String fmt = MakeStringFromC("");
   P_uintptr n = args.len;
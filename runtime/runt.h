@@ -6,6 +6,7 @@
 #include <assert.h>
 #include <errno.h>
 #include <memory.h>
+#include <setjmp.h>
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
@@ -31,12 +32,13 @@ typedef void (*omarker)();  // TODO: GC
 
 /* #include <cmoc.h> */
 
-// #include "../pythonine/v0.1/octet.h"
-#include "frob3/octet/octet2.h"
-
 #include "frob3/froblib.h"
 #include "frob3/frobos9.h"
 
+#include "runtime/os9_heap.h"
+
+#include <setjmp.h>
+
 //typedef unsigned char bool;
 //typedef unsigned char byte;
 //typedef unsigned int word;
@@ -50,9 +52,11 @@ typedef void (*omarker)();  // TODO: GC
 #define P_true 1
 #define P_false 0
 #define NIL ((word)0)
+#define P_nil 0  // for handles, like Chan and Map, and pointers.
 
 #define Slice_(T) Slice
 #define Map_(K, V) Map
+#define Chan_(T) Chan
 #define Interface_(NAME) VoidStar
 #define Struct_(NAME) word
 #define Pointer_(NAME) VoidStar
@@ -73,6 +77,7 @@ enum ClsNum {
   C_String = 3,
   C_Slice = 4,
   C_Map = 5,
+  C_Chan = 6,
 };
 
 typedef struct String {
@@ -88,6 +93,15 @@ typedef struct Slice {
   P_uint len;
 } Slice;
 
+typedef word Map;
+typedef word Chan;
+
+typedef void (*FuncPtr)();
+typedef struct Func {
+  FuncPtr fn;  // the C function.
+  void* env;   // for lambdas, the frame of the enclosing func.
+} Func;
+
 typedef struct Any {
   void* pointer;  // for everything else
   const char* typecode;
@@ -102,14 +116,24 @@ extern void F_BUILTIN_println(int i);
 extern void panic_s(const char*);
 
 // Strings
+extern String MakeString(const char* p, int n);
 extern String MakeStringFromC(const char* s);
 extern char* MakeCStrFromString(String s);
 extern String StringAdd(String a, String b);
 extern void StringGet(String a, int nth, P_byte* out);
+extern int StringCompare(String a, String b);
+extern bool StringEQ(String a, String b);
+extern bool StringNE(String a, String b);
+extern bool StringLT(String a, String b);
+extern bool StringLE(String a, String b);
+extern bool StringGT(String a, String b);
+extern bool StringGE(String a, String b);
 
 // String & Slice
 String FromBytesToString(Slice a);
 Slice FromStringToBytes(String a);
+String FromRuneToString(P_int r);
+Slice FromStringToRunes(String a);
 
 // Slices
 extern Slice MakeSlice(const char* typecode, int len, int cap, int size);
@@ -118,6 +142,49 @@ extern Slice SliceAppend(Slice a, void* new_elem_ptr, int new_elem_size, byte ba
 extern void SliceGet(Slice a, int size, int nth, void* value);
 extern void SlicePut(Slice a, int size, int nth, void* value);
 extern int SliceLen(Slice a, int size);
+
+// Arrays
+extern P_int CheckIndex(P_int i, P_int n);
+extern void CheckSlice(P_int a, P_int b, P_int n);
+
+// Maps
+extern Map MakeMap(const char* eshape, byte kind, byte ksize, byte vsize);
+extern bool MapGet(Map a, void* key, void* value, int vsize);
+extern void MapPut(Map a, void* key, void* value);
+extern void MapDelete(Map a, void* key);
+extern P_int MapLen(Map a);
+extern bool MapNext(Map a, P_uint* bucket, P_uint* seq, void* key, void* value);
+
+// Chans
+extern Chan MakeChan(const char* eshape, byte esize, int cap);
+extern void ChanSend(Chan c, void* value);
+extern bool ChanRecv(Chan c, void* value);
+extern void ChanClose(Chan c);
+extern P_int ChanLen(Chan c);
+
+#define SELECT_MAX 8
+typedef struct SelectCase {
+  Chan c;
+  void* data;  // The element to send, or where to receive it.
+  bool send;
+  bool ok;  // After receiving, false if the Chan was closed.
+} SelectCase;
+extern P_int Select(SelectCase* cases, P_int n, bool hasDefault);
+
+// Interfaces
+extern byte HandleClass(word h);  // 0 for nil.
+extern byte AnyClass(Any a);  // 0 unless it holds a struct pointer.
+extern void PanicAssertAny(Any a, const char* want);
+extern void PanicAssertHandle(word h, const char* want);
+
+// Timers
+extern word Millis();  // A clock, which may wrap around.
+extern void SleepMillis(word ms);
+extern bool CheckTimers(bool sleep);
+extern Chan time__After(P_int ms);
+extern void time__Sleep(P_int ms);
+
+extern word CheckLen(int i);
 extern void builtin__println(Slice args);
 
 // Format
@@ -150,6 +217,43 @@ void Write2();
 void Where();  // Show calling function frames.
 #endif
 
+// Panics
+typedef struct Catcher {
+  jmp_buf jb;
+  struct Catcher* prev;
+  struct Frame* deferring;  // DeferringFrame, when this was installed.
+} Catcher;
+extern Catcher* CurrentCatcher;
+extern struct Frame* DeferringFrame;
+extern bool PanicActive;
+extern Any Panicking;
+extern void Panic(Any a);
+extern void Repanic();
+extern P__any_ builtin__recover();
+
+// Goroutines
+#define GO_ARGS_WORDS 12
+typedef struct Goroutine {
+  struct Goroutine* next;     // in the run queue.
+  struct Goroutine* allnext;  // in AllGoroutines, for the GC.
+  struct Frame* frame;        // CurrentFrame, while switched out.
+  Catcher* catcher;           // CurrentCatcher, while switched out.
+  struct Frame* deferring;    // DeferringFrame, while switched out.
+  void (*thunk)(void* args);  // calls the func of the `go` statement.
+  const char* argshape;       // GC mark shape for args.
+  void* ctx;                  // machine context, see *_sched.c.
+  word args[GO_ARGS_WORDS];   // the func and args, already evaluated.
+} Goroutine;
+extern Goroutine* CurrentG;
+extern void Go(void (*thunk)(void*), const void* args, int size, const char* argshape);
+extern void Ready(Goroutine* g);
+extern void Park();
+extern void GoStart();
+// These depend on the machine, in unix_sched.c or os9_sched.c:
+extern Goroutine* NewG();  // with a stack that starts in GoStart().
+extern void FreeG(Goroutine* g);
+extern void SwitchG(Goroutine* from, Goroutine* to);
+
 #define RETURN return (CurrentFrame = fr.fr_prev),
 
 #define RETURN_NOTHING         \
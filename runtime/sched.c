@@ -0,0 +1,99 @@
+#include "___.defs.h"
+
+// Goroutines are scheduled cooperatively: the current one runs
+// until it blocks on a Chan (or exits), and then the next one
+// in the run queue gets switched in.  So there are no locks.
+// Each has its own C stack, and its own chain of Frames.
+
+static Goroutine MainG;
+Goroutine* CurrentG = &MainG;
+Goroutine* AllGoroutines = &MainG;
+
+static Goroutine* RunHead;
+static Goroutine* RunTail;
+static Goroutine* Zombie;  // exited, but was still on its own stack.
+
+void Ready(Goroutine* g) {
+  g->next = NULL;
+  if (RunTail) {
+    RunTail->next = g;
+  } else {
+    RunHead = g;
+  }
+  RunTail = g;
+}
+
+static void Reap() {
+  if (Zombie) {
+    FreeG(Zombie);
+    Zombie = NULL;
+  }
+}
+
+// Park switches to the next ready goroutine.  The caller must
+// have arranged to be made Ready again, or it never returns.
+// If no goroutine is ready, it sleeps until a timer fires.
+void Park() {
+  CheckTimers(false);
+  while (!RunHead) {
+    if (!CheckTimers(true)) {
+      fprintf(stderr, "\nfatal error: all goroutines are asleep - deadlock!\n");
+      exit(2);
+    }
+  }
+  Goroutine* g = RunHead;
+  RunHead = g->next;
+  if (!RunHead) RunTail = NULL;
+
+  Goroutine* from = CurrentG;
+  from->frame = CurrentFrame;
+  from->catcher = CurrentCatcher;
+  from->deferring = DeferringFrame;
+  CurrentG = g;
+  CurrentFrame = g->frame;
+  CurrentCatcher = g->catcher;
+  DeferringFrame = g->deferring;
+  if (g != from) SwitchG(from, g);
+  Reap();
+}
+
+void Go(void (*thunk)(void*), const void* args, int size, const char* argshape) {
+  if (size > (int)sizeof(MainG.args)) panic_s("go: too many args");
+  Goroutine* g = NewG();
+  if (!g) panic_s("go: too many goroutines");
+  g->frame = NULL;
+  g->catcher = NULL;
+  g->deferring = NULL;
+  g->thunk = thunk;
+  g->argshape = argshape;
+  if (size) memcpy(g->args, args, size);
+  g->allnext = AllGoroutines;
+  AllGoroutines = g;
+  Ready(g);
+}
+
+// GoStart is the bottom of every goroutine's stack, except main's.
+void GoStart() {
+  Reap();
+  CurrentG->thunk(CurrentG->args);
+
+  Goroutine** pp = &AllGoroutines;
+  while (*pp != CurrentG) pp = &(*pp)->allnext;
+  *pp = CurrentG->allnext;
+
+  Zombie = CurrentG;
+  Park();  // Never returns.
+  assert(0);
+}
+
+#ifndef unix
+extern void mark_frames(struct Frame* fr);
+extern void mark_with_shape(const char* s, word h);
+
+void mark_goroutines() {
+  for (Goroutine* g = AllGoroutines; g; g = g->allnext) {
+    if (g != CurrentG) mark_frames(g->frame);
+    mark_with_shape(g->argshape, (word)g->args - 1);
+  }
+}
+#endif
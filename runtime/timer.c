@@ -0,0 +1,74 @@
+#include "___.defs.h"
+
+// A timer is a Chan with a buffer of 1, which gets `true`
+// when the timer fires.  Timers are only checked when a
+// goroutine parks, since the scheduler is cooperative.
+// Pending timers are a list of Timer objects in the GC Heap,
+// so there may be any number of them.  A timer leaves the list
+// when it fires, even if nothing receives from its Chan.
+
+typedef struct Timer {
+  struct Timer* next;
+  Chan c;
+  word when;
+} Timer;
+
+static Timer* Timers;
+
+Chan time__After(P_int ms) {
+  Timer* t = (Timer*)oalloc(CheckLen(sizeof(Timer)), C_Bytes);
+  assert(t);
+  t->next = Timers;
+  Timers = t;  // Now the GC sees t, while making the Chan.
+  t->c = MakeChan("", sizeof(P_bool), 1);
+  t->when = Millis() + (word)(ms < 0 ? 0 : ms);
+  return t->c;
+}
+
+void time__Sleep(P_int ms) {
+  P_bool b;
+  ChanRecv(time__After(ms), &b);
+}
+
+// CheckTimers fires the timers that are due.  If sleep,
+// it first sleeps until the next one is due.
+// It returns false if there are no timers.
+bool CheckTimers(bool sleep) {
+  bool any = false;
+  word now = Millis();
+  P_int least = 0;
+  for (Timer* t = Timers; t; t = t->next) {
+    P_int d = (P_int)(t->when - now);
+    if (!any || d < least) least = d;
+    any = true;
+  }
+  if (!any) return false;
+  if (sleep && least > 0) {
+    SleepMillis((word)least);
+    now = Millis();
+  }
+
+  Timer** pp = &Timers;
+  while (*pp) {
+    Timer* t = *pp;
+    if ((P_int)(t->when - now) > 0) {
+      pp = &t->next;
+      continue;
+    }
+    *pp = t->next;
+    P_bool b = true;
+    ChanSend(t->c, &b);  // Never blocks, with the buffer of 1.
+  }
+  return true;
+}
+
+#ifndef unix
+extern void mark_handle(word h);
+
+void mark_timers() {
+  for (Timer* t = Timers; t; t = t->next) {
+    mark_handle((word)t);
+    mark_handle(t->c);
+  }
+}
+#endif
@@ -6,9 +6,9 @@ void oinit(word begin, word end, omarker fn) {
   fprintf(stderr, "## oinit: noop\n");
 }
 
-word oalloc(byte len, byte cls) {
-  assert(len < INF);
-  byte cap = (len + 1) & 0xFE;
+word oalloc(word len, byte cls) {
+  assert(len <= MAX_OBJ_LEN);
+  word cap = (len + 1) & ~(word)1;
   size_t sz = sizeof(BigHeader) + cap + 8;
   BigHeader* h = malloc(sz);
   memset(h, 0, sz);
@@ -34,7 +34,7 @@ bool ovalidaddr(word addr) {
   return true;
 }
 
-byte ocap(word addr) {
+word ocap(word addr) {
   assert(ovalidaddr(addr));
   BigHeader* bh = (BigHeader*)addr - 1;
   return bh->cap;
@@ -49,17 +49,23 @@ byte ocls(word addr) {
 void osay(word addr) {
   assert(ovalidaddr(addr));
   BigHeader* bh = (BigHeader*)addr - 1;
-  fprintf(stderr, " [[cls=%d cap=%d ", bh->cap, bh->cls);
-  for (int i = 0; i < bh->cap; i++) {
+  fprintf(stderr, " [[cls=%d cap=%d ", bh->cls, (int)bh->cap);
+  for (word i = 0; i < bh->cap; i++) {
     fprintf(stderr, "%02x ", ((char*)addr)[i]);
   }
   fprintf(stderr, "]]\n");
 }
 
-void omemcpy(word d, word s, byte n) { memcpy((char*)d, (char*)s, n); }
+void omemcpy(word d, word s, word n) { memcpy((char*)d, (char*)s, n); }
 
-int omemcmp(word pchar1, byte len1, word pchar2, byte len2) {
-  assert(0);  // memcpy((char*)d, (char*)s, n);
+// omemcmp compares two runs of bytes like memcmp,
+// with a shorter prefix coming first.
+int omemcmp(word pchar1, word len1, word pchar2, word len2) {
+  word n = (len1 < len2) ? len1 : len2;
+  int c = memcmp((char*)pchar1, (char*)pchar2, n);
+  if (c) return (c < 0) ? -1 : 1;
+  if (len1 == len2) return 0;
+  return (len1 < len2) ? -1 : 1;
 }
 
 #endif // unix
@@ -11,24 +11,27 @@
 #define GUARD_TWO 0xBB
 #define GUARD_THREE 0xCC
 
+// MAX_OBJ_LEN is the largest length oalloc can give.
+#define MAX_OBJ_LEN 0x7FFE
+
 typedef struct BigHeader {
   byte guard0;
   byte guard1;
-  byte cap;
+  word cap;
   byte guard2;
   byte cls;
   byte guard3;
 } BigHeader;
 
 void oinit(word begin, word end, omarker fn);
-word oalloc(byte len, byte cls);
+word oalloc(word len, byte cls);
 void ozero(word begin, word len);
 void ofree(word addr);  // Unsafe.
 bool ovalidaddr(word addr);
-byte ocap(word addr);  // capacity in bytes.
+word ocap(word addr);  // capacity in bytes.
 byte ocls(word addr);
 void osay(word addr);
-void omemcpy(word d, word s, byte n);
-int omemcmp(word pchar1, byte len1, word pchar2, byte len2);
+void omemcpy(word d, word s, word n);
+int omemcmp(word pchar1, word len1, word pchar2, word len2);
 
 #endif
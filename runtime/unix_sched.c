@@ -0,0 +1,37 @@
+#include "___.defs.h"
+
+#ifdef unix
+
+#include <ucontext.h>
+
+#define GO_STACK_SIZE (64 * 1024)
+
+typedef struct UnixG {
+  Goroutine g;  // must be first.
+  ucontext_t uc;
+  char stack[GO_STACK_SIZE];
+} UnixG;
+
+static ucontext_t MainContext;
+
+Goroutine* NewG() {
+  UnixG* u = malloc(sizeof *u);
+  assert(u);
+  memset(&u->g, 0, sizeof u->g);
+  getcontext(&u->uc);
+  u->uc.uc_stack.ss_sp = u->stack;
+  u->uc.uc_stack.ss_size = sizeof u->stack;
+  u->uc.uc_link = NULL;
+  makecontext(&u->uc, GoStart, 0);
+  u->g.ctx = &u->uc;
+  return &u->g;
+}
+
+void FreeG(Goroutine* g) { free(g); }
+
+void SwitchG(Goroutine* from, Goroutine* to) {
+  if (!from->ctx) from->ctx = &MainContext;  // main's stack.
+  swapcontext((ucontext_t*)from->ctx, (ucontext_t*)to->ctx);
+}
+
+#endif  // unix
@@ -0,0 +1,20 @@
+#include "___.defs.h"
+
+#ifdef unix
+
+#include <time.h>
+
+word Millis() {
+  struct timespec ts;
+  clock_gettime(CLOCK_MONOTONIC, &ts);
+  return (word)ts.tv_sec * 1000 + ts.tv_nsec / 1000000;
+}
+
+void SleepMillis(word ms) {
+  struct timespec ts;
+  ts.tv_sec = ms / 1000;
+  ts.tv_nsec = (ms % 1000) * 1000000;
+  nanosleep(&ts, NULL);
+}
+
+#endif  // unix
@@ -0,0 +1,81 @@
+package main
+
+type Point struct {
+	x int
+	y int
+}
+
+func main() {
+	ages := make(map[string]int)
+	ages["alice"] = 31
+	ages["bob"] = 42
+	ages["carol"] = 27
+	println(len(ages), ages["alice"], ages["bob"], ages["carol"])
+
+	ages["bob"] = 43
+	println(len(ages), ages["bob"])
+
+	v, ok := ages["dave"]
+	println(v, ok)
+	v, ok = ages["carol"]
+	println(v, ok)
+
+	delete(ages, "alice")
+	delete(ages, "nobody")
+	_, ok = ages["alice"]
+	println(len(ages), ok)
+
+	sum := 0
+	count := 0
+	for k, e := range ages {
+		sum = sum + e
+		count = count + len(k)
+	}
+	println(sum, count)
+
+	points := make(map[int]*Point)
+	for i := 0; i < 40; i++ {
+		points[i] = &Point{x: i, y: i * i}
+	}
+	total := 0
+	for n := range points {
+		p := points[n]
+		total = total + p.y - p.x
+	}
+	println(len(points), total)
+
+	squares := make(map[int]int)
+	for i := 0; i < 64; i++ {
+		squares[i] = i * i
+	}
+	deleted := 0
+	for sq := range squares {
+		delete(squares, sq)
+		deleted++
+	}
+	println(deleted, len(squares))
+
+	for i := 0; i < 64; i++ {
+		squares[i] = i
+	}
+	seen := 0
+	for sq := range squares {
+		delete(squares, sq+1-2*(sq%2))
+		seen++
+	}
+	println(seen, len(squares))
+
+	var empty map[string]int
+	println(len(empty), empty["x"])
+}
+
+// expect: 3 31 42 27
+// expect: 3 43
+// expect: 0 false
+// expect: 27 true
+// expect: 2 false
+// expect: 70 8
+// expect: 40 19760
+// expect: 64 0
+// expect: 32 32
+// expect: 0 0
@@ -0,0 +1,51 @@
+package main
+
+func apply(f func(x int) int, x int) int {
+	return f(x)
+}
+
+func each(words []string, visit func(i int, w string)) {
+	for i, w := range words {
+		visit(i, w)
+	}
+}
+
+func scale(factor int) int {
+	times := func(x int) int {
+		return x * factor
+	}
+	return apply(times, 7)
+}
+
+func main() {
+	total := 0
+	add := func(n int) {
+		total = total + n
+	}
+	add(3)
+	add(4)
+	println(total)
+
+	double := func(x int) int { return 2 * x }
+	println(double(21), apply(double, 50))
+	println(apply(func(x int) int { return x + total }, 100))
+	println(scale(6))
+
+	var words []string
+	words = append(words, "zero")
+	words = append(words, "one")
+	count := 0
+	each(words, func(i int, w string) {
+		println(i, w)
+		count = count + len(w)
+	})
+	println(count)
+}
+
+// expect: 7
+// expect: 42 100
+// expect: 107
+// expect: 42
+// expect: 0 zero
+// expect: 1 one
+// expect: 7
@@ -0,0 +1,104 @@
+package main
+
+var trace string
+
+func note(s string) {
+	trace = trace + s
+}
+
+func early(stop bool) int {
+	defer note("a")
+	if stop {
+		return 1
+	}
+	defer note("b")
+	return 2
+}
+
+func safeIndex(a []int, i int) bool {
+	defer func() {
+		if recover() != nil {
+			note("r")
+		}
+	}()
+	x := a[i]
+	return x > 0
+}
+
+func mustPositive(n int) int {
+	defer note("m")
+	if n <= 0 {
+		panic("not positive")
+	}
+	return n
+}
+
+func catch() string {
+	defer func() {
+		r := recover()
+		println("recovered:", r)
+	}()
+	mustPositive(-3)
+	return "unreached"
+}
+
+func keep() int {
+	total := 5
+	defer func() {
+		total = 99
+	}()
+	return total
+}
+
+func helperRecover() interface{} {
+	return recover()
+}
+
+func indirect() {
+	defer func() {
+		if helperRecover() == nil {
+			note("n")
+		}
+	}()
+	panic("deep")
+}
+
+func outer() {
+	defer func() {
+		r := recover()
+		if r != nil {
+			note(r.(string))
+		}
+	}()
+	indirect()
+}
+
+func main() {
+	trace = "-"
+	println(early(true), trace)
+	trace = "-"
+	println(early(false), trace)
+	trace = "-"
+
+	a := make([]int, 3)
+	a[1] = 2
+	println(safeIndex(a, 1), safeIndex(a, 5), trace)
+	trace = "-"
+
+	println(mustPositive(4), trace)
+	trace = "-"
+	println(len(catch()), trace)
+	println(keep())
+	trace = "-"
+	outer()
+	println(trace)
+}
+
+// expect: 1 -a
+// expect: 2 -ba
+// expect: true false -r
+// expect: 4 -m
+// expect: recovered: not positive
+// expect: 0 -m
+// expect: 5
+// expect: -ndeep
@@ -0,0 +1,84 @@
+package main
+
+type Counter struct {
+	total int
+}
+
+func (c *Counter) Count(in chan int, done chan bool) {
+	for x := range in {
+		c.total = c.total + x
+	}
+	done <- true
+}
+
+func produce(n int, out chan int) {
+	for i := 1; i <= n; i++ {
+		out <- i
+	}
+	close(out)
+}
+
+func squares(in chan int, out chan int) {
+	for {
+		x, ok := <-in
+		if ok == false {
+			break
+		}
+		out <- x * x
+	}
+	close(out)
+}
+
+func speak(words chan string) {
+	words <- "hello"
+	words <- "world"
+	close(words)
+}
+
+func main() {
+	// Unbuffered pipeline.
+	nums := make(chan int)
+	sq := make(chan int)
+	go produce(4, nums)
+	go squares(nums, sq)
+	for y := range sq {
+		println(y)
+	}
+
+	// Buffered, so no goroutine is needed.
+	buf := make(chan string, 3)
+	buf <- "a"
+	buf <- "b"
+	println(len(buf), <-buf, <-buf, len(buf))
+
+	// A method as a goroutine.
+	c := &Counter{}
+	in := make(chan int, 2)
+	done := make(chan bool)
+	go c.Count(in, done)
+	for i := 0; i < 10; i++ {
+		in <- i
+	}
+	close(in)
+	<-done
+	println(c.total)
+
+	// Closing ends the receiver's range.
+	words := make(chan string)
+	go speak(words)
+	for w := range words {
+		println(w)
+	}
+	_, ok := <-words
+	println(ok)
+}
+
+// expect: 1
+// expect: 4
+// expect: 9
+// expect: 16
+// expect: 2 a b 0
+// expect: 45
+// expect: hello
+// expect: world
+// expect: false
@@ -0,0 +1,100 @@
+package main
+
+import "time"
+
+func numbers(out chan int, n int) {
+	for i := 0; i < n; i++ {
+		out <- i
+	}
+	close(out)
+}
+
+func ticker(out chan string, quit chan bool) {
+	for {
+		select {
+		case out <- "tick":
+		case <-quit:
+			out <- "bye"
+			return
+		}
+	}
+}
+
+func main() {
+	// Default, when nothing is ready.
+	idle := make(chan int)
+	select {
+	case v := <-idle:
+		println("unexpected", v)
+	default:
+		println("nothing ready")
+	}
+
+	// Merge two producers until both are closed.
+	a := make(chan int)
+	b := make(chan int)
+	go numbers(a, 3)
+	go numbers(b, 4)
+	sum := 0
+	open := 2
+	for open > 0 {
+		select {
+		case x, ok := <-a:
+			if ok == false {
+				a = nil
+				open--
+				break
+			}
+			sum = sum + x
+		case y, ok := <-b:
+			if ok == false {
+				b = nil
+				open--
+				break
+			}
+			sum = sum + 10*y
+		}
+	}
+	println(sum)
+
+	// Sending in a select.
+	out := make(chan string)
+	quit := make(chan bool)
+	go ticker(out, quit)
+	println(<-out, <-out)
+	quit <- true
+	println(<-out)
+
+	// Timeout.
+	never := make(chan int)
+	select {
+	case <-never:
+		println("unexpected")
+	case <-time.After(20):
+		println("timeout")
+	}
+	time.Sleep(5)
+	println("slept")
+
+	// Timers that never fire before their select is done.
+	ready := make(chan int, 1)
+	got := 0
+	for i := 0; i < 20; i++ {
+		ready <- i
+		select {
+		case v := <-ready:
+			got = got + v
+		case <-time.After(1000):
+			println("unexpected timeout")
+		}
+	}
+	println(got)
+}
+
+// expect: nothing ready
+// expect: 63
+// expect: tick tick
+// expect: bye
+// expect: timeout
+// expect: slept
+// expect: 190
@@ -0,0 +1,108 @@
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Namer interface {
+	Name() string
+}
+
+type Square struct {
+	side int
+}
+
+type Rect struct {
+	w int
+	h int
+}
+
+func (p *Square) Area() int {
+	return p.side * p.side
+}
+func (p *Square) Name() string {
+	return "square"
+}
+func (p *Rect) Area() int {
+	return p.w * p.h
+}
+
+func describe(x interface{}) string {
+	switch v := x.(type) {
+	case nil:
+		return "nil"
+	case int:
+		return "int"
+	case string:
+		return "string " + v
+	case *Square:
+		if v.side > 2 {
+			return "big square"
+		}
+		return "square"
+	case Shape:
+		println("area", v.Area())
+		return "shape"
+	case bool, byte:
+		return "bool or byte"
+	}
+	return "other"
+}
+
+func named(s Shape) string {
+	switch v := s.(type) {
+	case Namer:
+		return v.Name()
+	case nil:
+		return "no shape"
+	default:
+		println("area", v.Area())
+	}
+	return "unnamed"
+}
+
+func kind(s Shape) int {
+	switch s.(type) {
+	case *Square:
+		return 1
+	case *Rect:
+		return 2
+	}
+	return 0
+}
+
+func main() {
+	var nothing interface{}
+	println(describe(nothing))
+	println(describe(42))
+	println(describe("hi"))
+	println(describe(&Square{side: 1}))
+	println(describe(&Square{side: 5}))
+	println(describe(&Rect{w: 2, h: 3}))
+	println(describe(true))
+	println(describe(byte(7)))
+	var e []int
+	println(describe(e))
+
+	var s Shape
+	println(named(s))
+	s = &Square{side: 3}
+	println(named(s), kind(s))
+	s = &Rect{w: 4, h: 5}
+	println(named(s), kind(s))
+}
+
+// expect: nil
+// expect: int
+// expect: string hi
+// expect: square
+// expect: big square
+// expect: area 6
+// expect: shape
+// expect: bool or byte
+// expect: bool or byte
+// expect: other
+// expect: no shape
+// expect: square 1
+// expect: area 20
+// expect: unnamed 2
@@ -0,0 +1,107 @@
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Namer interface {
+	Name() string
+}
+
+type NamedShape interface {
+	Area() int
+	Name() string
+}
+
+type Apple struct {
+	size int
+}
+
+type Brick struct {
+	w int
+	h int
+}
+
+func (p *Apple) Area() int {
+	return p.size
+}
+func (p *Apple) Name() string {
+	return "apple"
+}
+func (p *Brick) Area() int {
+	return p.w * p.h
+}
+
+var caught string
+
+func catch() {
+	r := recover()
+	if r != nil {
+		caught = r.(string)
+	}
+}
+
+func mustBrick(s Shape) int {
+	defer catch()
+	b := s.(*Brick)
+	return b.w
+}
+
+func mustInt(x interface{}) int {
+	defer catch()
+	n := x.(int)
+	return n
+}
+
+func main() {
+	var s Shape
+	s = &Apple{size: 3}
+	a, ok := s.(*Apple)
+	println(ok, a.size)
+	b, ok2 := s.(*Brick)
+	println(ok2, b == nil)
+	n, ok3 := s.(Namer)
+	println(ok3, n.Name())
+
+	s = &Brick{w: 2, h: 5}
+	_, ok4 := s.(Namer)
+	println(ok4)
+	println(s.(*Brick).Area())
+
+	var x interface{}
+	x = "hello"
+	str, ok5 := x.(string)
+	println(ok5, str)
+	i, ok6 := x.(int)
+	println(ok6, i)
+	x = a
+	sh, ok7 := x.(Shape)
+	println(ok7, sh.Area())
+	x = nil
+	_, ok8 := x.(string)
+	println(ok8)
+
+	s = &Apple{size: 1}
+	println(mustBrick(s), caught)
+	println(mustInt(7))
+	println(mustInt("seven"), caught)
+
+	var ns NamedShape
+	ns = &Apple{size: 4}
+	s = ns
+	println(s.Area(), ns.Name())
+}
+
+// expect: true 3
+// expect: false true
+// expect: true apple
+// expect: false
+// expect: 10
+// expect: true hello
+// expect: false 0
+// expect: true 3
+// expect: false
+// expect: 0 interface conversion: interface is *main__Apple, not *main__Brick
+// expect: 7
+// expect: 0 interface conversion: interface is string, not int
+// expect: 4 apple
@@ -0,0 +1,93 @@
+package main
+
+var calls int
+
+func yes(s string) bool {
+	calls++
+	println("yes", s)
+	return true
+}
+
+func no(s string) bool {
+	calls++
+	println("no", s)
+	return false
+}
+
+func main() {
+	if yes("a") && no("b") {
+		println("wrong")
+	}
+	if no("c") && yes("d") {
+		println("wrong")
+	}
+	if yes("e") || no("f") {
+		println("or ok")
+	}
+	if no("g") || yes("h") && !no("i") {
+		println("mixed ok")
+	}
+	println(calls)
+
+	words := make([]string, 1)
+	i := 3
+	if i < len(words) && len(words[i]) == 0 {
+		println("wrong")
+	}
+	println(!true, !(i > 2), true && !false)
+
+	x := 100
+	x += 5
+	x -= 1
+	x *= 3
+	x /= 4
+	x %= 50
+	println(x)
+	y := 12
+	y &= 10
+	y |= 5
+	y ^= 3
+	println(y)
+	z := 1
+	z <<= 10
+	z >>= 3
+	println(z)
+	w := 255
+	w &^= 15
+	println(w, w&^16, ^w, ^0)
+	var b byte
+	b = 7
+	b <<= 5
+	println(b, b>>2, b&3|1, b^255)
+
+	s := "ab"
+	s += "cd"
+	println(s)
+
+	nums := make([]int, 3)
+	nums[1] = 2
+	nums[1] += 40
+	m := make(map[string]int)
+	m["k"] += 7
+	m["k"] *= 6
+	println(nums[1], m["k"])
+}
+
+// expect: yes a
+// expect: no b
+// expect: no c
+// expect: yes e
+// expect: or ok
+// expect: no g
+// expect: yes h
+// expect: no i
+// expect: mixed ok
+// expect: 7
+// expect: false false true
+// expect: 28
+// expect: 14
+// expect: 128
+// expect: 240 224 -241 -1
+// expect: 224 56 1 31
+// expect: abcd
+// expect: 42 42
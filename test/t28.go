@@ -0,0 +1,27 @@
+package main
+
+func main() {
+	println(0x10, 0XfF, 0o17, 0O7)
+	println(0755, 0b1010, 0B11)
+	println(1_000, 0x_7F_FF, 0b_1000_0000)
+	var addr uintptr
+	addr = 0xFF00
+	println(addr == 65280)
+	var u uint
+	u = 0xFFFF
+	println(u == 65535)
+	var b byte
+	b = 0xFF
+	b = b & 0x0F
+	println(b, byte(0377), byte(0b11110000)>>4)
+	i := -0x8000 + 0x7FFF
+	println(i)
+}
+
+// expect: 16 255 15 7
+// expect: 493 10 3
+// expect: 1000 32767 128
+// expect: true
+// expect: true
+// expect: 15 255 15
+// expect: -1
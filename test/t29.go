@@ -0,0 +1,25 @@
+package main
+
+/* A block comment
+   over several lines. */
+
+func main() {
+	println("tab[\t] quote[\"] backslash[\\] hex[\x41\x42] octal[\101]")
+	println(len("\x1b[0m"), len("\x1bA"), len("é"), len("\U0001F600"))
+	println(`raw \t "string"`)
+	println(`line one
+line two`)
+	x := /* inline */ 5
+	println(x, 'A', '\n', '\'')
+	println('\x7f', '\377', 'é', '\u00e9')
+	println("trigraph??=", "question?")
+}
+
+// expect: tab[	] quote["] backslash[\] hex[AB] octal[A]
+// expect: 4 2 2 4
+// expect: raw \t "string"
+// expect: line one
+// expect: line two
+// expect: 5 65 10 39
+// expect: 127 255 233 233
+// expect: trigraph??= question?
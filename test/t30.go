@@ -0,0 +1,83 @@
+package main
+
+import ( "fmt"; "time" )
+
+const (
+	Red = iota
+	Green
+	Blue
+)
+
+const (
+	_  = iota
+	KB = 1 << (3 * iota)
+	MB
+)
+
+const (
+	A = iota * 10
+	B
+	C = 7
+	D
+	E = iota + 1
+)
+
+const ( X = iota; Y; Z )
+
+const Answer = 42
+const Half = Answer / 2
+
+const (
+	Name  = "gosub"
+	Debug = false
+)
+
+var (
+	count int
+	label string
+)
+
+type (
+	Pair struct {
+		a int
+		b int
+	}
+	Summer interface {
+		Sum() int
+	}
+)
+
+func (p *Pair) Sum() int {
+	return p.a + p.b
+}
+
+func main() {
+	println(Red, Green, Blue)
+	println(KB, MB)
+	println(A, B, C, D, E)
+	println(X, Y, Z)
+	println(Answer, Half, Name, Debug)
+	count = Blue + 1
+	label = fmt.Sprintf("%d", count)
+	println(count, label)
+	var s Summer
+	s = &Pair{a: Green, b: Answer}
+	println(s.Sum())
+	time.Sleep(2 * time.Millisecond)
+	println(time.Second)
+	x := 0
+	if Debug == false && count > Green {
+		x = Half
+	}
+	println(x)
+}
+
+// expect: 0 1 2
+// expect: 8 64
+// expect: 0 10 7 7 5
+// expect: 0 1 2
+// expect: 42 21 gosub false
+// expect: 3 3
+// expect: 43
+// expect: 1000
+// expect: 21
@@ -0,0 +1,39 @@
+package main
+
+const Name string = "gosub"
+const Greeting = "hello, " + Name
+const Mask byte = 0x7F
+const High = Mask &^ 0x0F
+const Shifted = Mask >> 4
+const Big uint = 0xFFFF
+const Small = Big - 0xFFF0
+
+const (
+	Debug   = false
+	Verbose = !Debug && true
+	Same    = Name == "gosub"
+	Before  = "abc" < "abd"
+	Many    = 10 * 200 / 4
+)
+
+func main() {
+	println(Greeting, len(Greeting))
+	println(Mask, High, Shifted, Small)
+	println(Debug, Verbose, Same, Before, Many)
+	var b byte
+	b = Mask
+	b = b & High
+	println(b, byte(100)+Mask-Mask, Mask|0x80)
+	s := Greeting + "!"
+	println(s)
+	var u uint
+	u = Big
+	println(u == 65535)
+}
+
+// expect: hello, gosub 12
+// expect: 127 112 7 15
+// expect: false true true true 500
+// expect: 112 100 255
+// expect: hello, gosub!
+// expect: true
@@ -0,0 +1,139 @@
+package main
+
+type Stringer interface {
+	String() string
+}
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+func (c Color) String() string {
+	switch c {
+	case Red:
+		return "red"
+	case Green:
+		return "green"
+	}
+	return "blue"
+}
+
+type Celsius int
+
+func (c Celsius) Fahrenheit() int {
+	return int(c*9/5 + 32)
+}
+
+type Path []string
+
+func (p Path) Len() int {
+	return len(p)
+}
+
+func (p Path) String() string {
+	z := ""
+	for _, e := range p {
+		z = z + "/" + e
+	}
+	return z
+}
+
+type Handler func(int) int
+
+func (h Handler) Twice(x int) int {
+	return h(h(x))
+}
+
+func show(s Stringer) {
+	println(s.String())
+}
+
+func kind(x interface{}) string {
+	switch x.(type) {
+	case int:
+		return "int"
+	case Celsius:
+		return "celsius"
+	case Path:
+		return "path"
+	case []string:
+		return "strings"
+	}
+	return "other"
+}
+
+func mustCelsius(x interface{}) (z string) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			z = r.(string)
+		}
+	}()
+	println(int(x.(Celsius)))
+	return "no panic"
+}
+
+func main() {
+	var c Color
+	c = Blue
+	println(Green.String(), c.String())
+	show(Red)
+	show(c)
+
+	var t Celsius
+	t = 100
+	println(t.Fahrenheit(), Celsius(-40).Fahrenheit())
+
+	p := Path(make([]string, 0))
+	p = append(p, "usr")
+	p = append(p, "lib")
+	println(p.Len(), p[1], len(p))
+	show(p)
+	println(p[1:].Len(), p[:1].String())
+
+	var h Handler
+	h = func(x int) int { return x * 3 }
+	println(h(2), h.Twice(2))
+
+	var s Stringer
+	s = Green
+	switch v := s.(type) {
+	case Path:
+		println("path", v.Len())
+	case Color:
+		println("color", int(v))
+	}
+	_, ok := s.(Path)
+	if !ok {
+		println("not a path")
+	}
+
+	var a interface{}
+	a = Celsius(9)
+	println(kind(a), kind(9), kind(p), kind([]string{}))
+	d, ok2 := a.(Celsius)
+	_, ok3 := a.(int)
+	a = 9
+	_, ok4 := a.(Celsius)
+	println(int(d), ok2, ok3, ok4)
+	println(mustCelsius(Celsius(5)), mustCelsius(7))
+}
+
+// expect: green blue
+// expect: red
+// expect: blue
+// expect: 212 -40
+// expect: 2 lib 2
+// expect: /usr/lib
+// expect: 1 /usr
+// expect: 6 18
+// expect: color 1
+// expect: not a path
+// expect: celsius int path strings
+// expect: 9 true false false
+// expect: 5
+// expect: no panic interface conversion: interface is int, not main__Celsius
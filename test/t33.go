@@ -0,0 +1,90 @@
+package main
+
+const N = 4
+
+type Node struct {
+	name string
+}
+
+type Buffer struct {
+	n     int
+	buf   [8]byte
+	guard int
+	names [2]string
+	kids  [N]*Node
+}
+
+func (b *Buffer) Put(c byte) {
+	b.buf[b.n] = c
+	b.n++
+}
+
+func sliceTo(b *Buffer, n int) (z string) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			z = r.(string)
+		}
+	}()
+	return string(b.buf[2:n])
+}
+
+func sum(a [N]int) int {
+	z := 0
+	for _, x := range a {
+		z += x
+	}
+	return z
+}
+
+func main() {
+	var a [N]int
+	for i := 0; i < len(a); i++ {
+		a[i] = i * 10
+	}
+	b := a
+	b[0] = 5
+	println(a[0], b[0], sum(a), sum(b))
+
+	var grid [2][3]int
+	grid[1][2] = 7
+	println(grid[1][2], len(grid), len(grid[0]))
+
+	buf := &Buffer{n: 0, guard: 12345}
+	buf.Put('h')
+	buf.Put('i')
+	buf.Put('!')
+	s := buf.buf[:buf.n]
+	println(len(s), string(s))
+	t := buf.buf[1:3]
+	t[1] = '?'
+	println(string(buf.buf[:buf.n]))
+	full := append(buf.buf[:], '.')
+	full[0] = 'H'
+	println(len(full), full[8], buf.guard, string(buf.buf[:1]))
+	println(sliceTo(buf, 3), sliceTo(buf, 9))
+
+	buf.names[1] = "world"
+	buf.kids[3] = &Node{name: "leaf"}
+	for i, k := range buf.kids {
+		if k != nil {
+			println(i, k.name, buf.names[1])
+		}
+	}
+
+	defer func() {
+		r := recover()
+		println("recovered", r.(string))
+	}()
+	i := 4
+	println(a[i])
+}
+
+// expect: 0 5 60 65
+// expect: 7 2 3
+// expect: 3 hi!
+// expect: hi?
+// expect: 9 46 12345 h
+// expect: ? slice bounds out of range
+// expect: 3 leaf world
+// expect: recovered array index out of range
@@ -0,0 +1,63 @@
+package main
+
+type Point struct {
+	x int
+	y int
+}
+
+type Line struct {
+	a    *Point
+	b    *Point
+	name string
+}
+
+func total(a []int) int {
+	z := 0
+	for _, e := range a {
+		z += e
+	}
+	return z
+}
+
+func names() []string {
+	return []string{"a", "bb", "ccc"}
+}
+
+func main() {
+	nums := []int{1, 2, 3}
+	println(len(nums), total(nums), total([]int{}))
+
+	for i, s := range names() {
+		println(i, s)
+	}
+
+	p := &Point{3, 4}
+	q := &Point{y: 6}
+	println(p.x, p.y, q.x, q.y)
+
+	line := &Line{&Point{1, 2}, q, "diag"}
+	println(line.a.y, line.b.y, line.name)
+
+	grid := [][]byte{
+		{'a', 'b'},
+		{'c'},
+		[]byte{'d', 'e', 'f'},
+	}
+	println(len(grid), len(grid[2]), string(grid[0]), string(grid[2]))
+
+	pts := []*Point{{5, 6}, &Point{x: 7}}
+	println(pts[0].y, pts[1].x)
+
+	arr := [4]int{9, 8}
+	println(arr[0], arr[1], arr[3], len(arr))
+}
+
+// expect: 3 6 0
+// expect: 0 a
+// expect: 1 bb
+// expect: 2 ccc
+// expect: 3 4 0 6
+// expect: 2 6 diag
+// expect: 3 3 ab def
+// expect: 6 7
+// expect: 9 8 0 4
@@ -0,0 +1,56 @@
+package main
+
+type Shape interface {
+	Area() int
+}
+
+type Square struct {
+	side int
+}
+
+func (s *Square) Area() int {
+	return s.side * s.side
+}
+
+type Box struct {
+	any   interface{}
+	face  Shape
+	small byte
+	big   uint
+	ok    bool
+	label string
+}
+
+func describe(b *Box) {
+	switch v := b.any.(type) {
+	case int:
+		println("int", v)
+	case string:
+		println("string", v)
+	case *Square:
+		println("square", v.side)
+	case nil:
+		println("nil")
+	}
+}
+
+func main() {
+	sq := &Square{side: 3}
+	b := &Box{any: 3, face: sq, small: 200, big: 60000, ok: true, label: "one"}
+	describe(b)
+	println(b.face.Area(), b.small, b.big, b.ok, b.label)
+
+	c := &Box{"two", nil, 'x', 7, false, "two"}
+	describe(c)
+	println(c.face == nil, c.small, c.big, c.ok)
+
+	describe(&Box{any: sq})
+	describe(&Box{})
+}
+
+// expect: int 3
+// expect: 9 200 60000 true one
+// expect: string two
+// expect: true 120 7 false
+// expect: square 3
+// expect: nil
@@ -0,0 +1,69 @@
+package main
+
+type Pair struct {
+	left  int
+	right int
+}
+
+var g1 int
+var g2 int
+
+func divmod(a int, b int) (q int, r int) {
+	return a / b, a % b
+}
+
+func main() {
+	a, b := 1, 2
+	a, b = b, a
+	println(a, b)
+
+	g1, g2 = 1, 2
+	g1, g2 = g2, g1
+	println(g1, g2)
+
+	x := []int{10, 20, 30}
+	i, j := 0, 2
+	x[i], x[j] = x[j], x[i]
+	println(x[0], x[1], x[2])
+
+	i, x[i] = 1, 99
+	println(i, x[0], x[1])
+
+	p := &Pair{left: 3, right: 4}
+	p.left, p.right = p.right, p.left
+	println(p.left, p.right)
+
+	q := &Pair{left: 5, right: 6}
+	old := p
+	p, p.left = q, 100
+	println(old.left, p.left)
+
+	var arr [3]string
+	arr[0], arr[1], arr[2] = "c", "b", "a"
+	arr[0], arr[2] = arr[2], arr[0]
+	println(arr[0], arr[1], arr[2])
+
+	m := make(map[string]int)
+	m["q"], m["r"] = divmod(17, 5)
+	println(m["q"], m["r"])
+
+	var any interface{}
+	var n int
+	any, n = 7, a
+	println(any.(int), n)
+
+	x[0], _ = divmod(9, 2)
+	_, x[2] = divmod(9, 2)
+	println(x[0], x[2])
+}
+
+// expect: 2 1
+// expect: 2 1
+// expect: 30 20 10
+// expect: 1 99 20
+// expect: 4 3
+// expect: 100 5
+// expect: a b c
+// expect: 3 2
+// expect: 7 2
+// expect: 4 1
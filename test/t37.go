@@ -0,0 +1,66 @@
+package main
+
+type Writer interface {
+	Write(p []byte) (n int, err interface{})
+}
+
+type Counter struct {
+	total int
+}
+
+func (c *Counter) Write(p []byte) (n int, err interface{}) {
+	c.total += len(p)
+	return len(p), nil
+}
+
+// Fprint forwards the results of the Writer.
+func Fprint(w Writer, p []byte) (n int, err interface{}) {
+	return w.Write(p)
+}
+
+func pair() (a int, b int) {
+	return 3, 4
+}
+
+func swap(a int, b int) (x int, y int) {
+	return b, a
+}
+
+func add(a int, b int) int {
+	return a + b
+}
+
+func sum(nums ...int) int {
+	z := 0
+	for _, e := range nums {
+		z += e
+	}
+	return z
+}
+
+func show(a interface{}, b interface{}) {
+	println(a.(int), b.(int))
+}
+
+func forward() (a int, b int) {
+	return swap(pair())
+}
+
+func main() {
+	println(add(pair()), sum(pair()))
+	x, y := forward()
+	println(x, y)
+	show(swap(pair()))
+
+	c := &Counter{}
+	n, err := Fprint(c, []byte{1, 2, 3, 4, 5})
+	println(n, err == nil)
+	Fprint(c, []byte{6, 7})
+	println(c.total)
+}
+
+// expect: 7 7
+// expect: 4 3
+// expect: 4 3
+// expect: 5 true
+// expect: 7
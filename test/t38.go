@@ -0,0 +1,71 @@
+package main
+
+func count(s []int) (n int) {
+	for _, e := range s {
+		if e > 0 {
+			n++
+		}
+	}
+	return
+}
+
+func split(sum int) (x int, y int) {
+	x = sum * 4 / 9
+	y = sum - x
+	return
+}
+
+func flip(a int, b int) (x int, y int) {
+	x, y = a, b
+	return y, x
+}
+
+func zeros() (n int, s string, ok bool) {
+	return
+}
+
+func safeDiv(a int, b int) (q int, err interface{}) {
+	defer func() {
+		r := recover()
+		if r != nil {
+			err = r
+		}
+	}()
+	if b == 0 {
+		panic("divide by zero")
+	}
+	q = a / b
+	return
+}
+
+func twice(x int) (z int) {
+	add := func() {
+		z += x
+	}
+	add()
+	add()
+	return
+}
+
+func main() {
+	println(count([]int{1, -2, 3, 0, 5}))
+	println(split(17))
+	println(flip(1, 2))
+	n, s, ok := zeros()
+	println(n, len(s), ok)
+
+	q, err := safeDiv(7, 2)
+	println(q, err == nil)
+	q, err = safeDiv(7, 0)
+	println(q, err.(string))
+
+	println(twice(21))
+}
+
+// expect: 3
+// expect: 7 10
+// expect: 2 1
+// expect: 0 0 false
+// expect: 3 true
+// expect: 0 divide by zero
+// expect: 42
@@ -0,0 +1,98 @@
+package main
+
+type Op func(int, int) int
+
+type Calc struct {
+	name string
+	op   Op
+	base int
+}
+
+func (c *Calc) Apply(x int) int {
+	return c.op(c.base, x)
+}
+
+type Celsius int
+
+func (c Celsius) Plus(d int) int {
+	return int(c) + d
+}
+
+type Shower interface {
+	Show(prefix string) string
+}
+
+func (c *Calc) Show(prefix string) string {
+	return prefix + c.name
+}
+
+func add(a int, b int) int {
+	return a + b
+}
+
+func mul(a int, b int) int {
+	return a * b
+}
+
+func apply(f func(int, int) int, a int, b int) int {
+	if f == nil {
+		return -1
+	}
+	return f(a, b)
+}
+
+func pick(n int) Op {
+	if n == 1 {
+		return add
+	}
+	return nil
+}
+
+func main() {
+	println(apply(add, 3, 4), apply(mul, 3, 4), apply(nil, 3, 4))
+
+	var f func(int, int) int
+	println(f == nil, nil == f)
+	f = mul
+	println(f != nil, f(5, 6))
+	f = nil
+	println(f == nil)
+
+	c := &Calc{name: "adder", op: add, base: 10}
+	println(c.Apply(5), c.op(1, 2))
+	c.op = mul
+	println(c.Apply(5))
+	println(pick(1)(2, 2), pick(2) == nil)
+
+	ops := []Op{add, mul}
+	println(ops[0](7, 8), ops[1](7, 8))
+
+	g := c.Apply
+	c.base = 2
+	println(g(4))
+	c = &Calc{name: "other", op: add, base: 100}
+	println(g(4))
+
+	t := Celsius(20)
+	h := t.Plus
+	t = 0
+	println(h(5))
+
+	var s Shower
+	s = c
+	show := s.Show
+	println(show("calc "))
+}
+
+// expect: 7 12 -1
+// expect: true true
+// expect: true 30
+// expect: true
+// expect: 15 3
+// expect: 50
+// expect: 4 true
+// expect: 15 56
+// expect: 8
+// expect: 8
+// expect: 25
+// expect: calc other
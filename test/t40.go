@@ -0,0 +1,129 @@
+package main
+
+type Animal interface {
+	Sound() int
+}
+
+type Dog struct {
+	name string
+}
+
+func (d *Dog) Sound() int {
+	return 1
+}
+
+func grade(score int) byte {
+	switch {
+	case score >= 90:
+		return 'A'
+	case score >= 80:
+		return 'B'
+	}
+	return 'F'
+}
+
+func kind(c byte) int {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u':
+		return 1
+	case ' ':
+		return 0
+	default:
+		return 2
+	}
+}
+
+func count(n int) int {
+	z := 0
+	switch n {
+	case 3:
+		z += 100
+		fallthrough
+	case 2:
+		z += 10
+		fallthrough
+	default:
+		z += 1
+	case 9:
+		z = 9
+	}
+	return z
+}
+
+func next() int {
+	return 7
+}
+
+func what(x interface{}) int {
+	switch x {
+	case nil:
+		return 0
+	case 5:
+		return 5
+	case true:
+		return 1
+	}
+	return -1
+}
+
+func main() {
+	println(grade(95), grade(85), grade(10))
+	println(kind('e'), kind(' '), kind('z'))
+	println(count(3), count(2), count(1), count(9))
+
+	switch x := next(); x {
+	case 7:
+		println("seven", x)
+	}
+
+	switch y := next() * 2; {
+	case y > 10:
+		println("big", y)
+	}
+
+	for i := 0; i < 5; i++ {
+		switch i {
+		case 2:
+			break
+		case 4:
+			println("four")
+		}
+		if i == 2 {
+			println("after break", i)
+		}
+	}
+
+	var d *Dog
+	var a Animal
+	println(what(nil), what(5), what(true), what(6))
+	d = &Dog{name: "rex"}
+	a = d
+	switch a {
+	case nil:
+		println("nil animal")
+	case d:
+		println("the dog", d.name)
+	}
+
+	var any interface{}
+	any = 3
+	switch v := any.(type) {
+	case int:
+		if v == 3 {
+			break
+		}
+		println("not reached")
+	}
+	println("done")
+}
+
+// expect: 65 66 70
+// expect: 1 0 2
+// expect: 111 11 1 9
+// expect: seven 7
+// expect: big 14
+// expect: after break 2
+// expect: four
+// expect: 0 5 1 -1
+// expect: the dog rex
+// expect: done
@@ -0,0 +1,52 @@
+package main
+
+func sort(a []string) {
+	for i := 1; i < len(a); i++ {
+		for j := i; j > 0 && a[j] < a[j-1]; j-- {
+			a[j], a[j-1] = a[j-1], a[j]
+		}
+	}
+}
+
+func command(name string) int {
+	switch name {
+	case "open", "close":
+		return 1
+	case "read":
+		return 2
+	}
+	return 0
+}
+
+func main() {
+	a := "abc"
+	b := "ab"
+	b = b + "c"
+	empty := ""
+	println(a == b, a != b, a < b, a <= b, a > b, a >= b)
+	println(b == "abc", "ab" < a, a < "abd", empty < a, empty == "")
+	println(a > "ab", a < "b", "B" < "a")
+
+	words := []string{"pear", "apple", "fig", "apricot", "", "figs"}
+	sort(words)
+	for i, w := range words {
+		println(i, "["+w+"]")
+	}
+
+	println(command("close"), command("read"), command("write"))
+	if words[1] == "apple" {
+		println("found apple")
+	}
+}
+
+// expect: true false false true false true
+// expect: true true true true true
+// expect: true true true
+// expect: 0 []
+// expect: 1 [apple]
+// expect: 2 [apricot]
+// expect: 3 [fig]
+// expect: 4 [figs]
+// expect: 5 [pear]
+// expect: 1 2 0
+// expect: found apple
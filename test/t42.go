@@ -0,0 +1,26 @@
+package main
+
+func main() {
+	nul := "a\x00b"
+	println(len(nul), nul[0], nul[1], nul[2])
+	two := nul + nul
+	println(len(two), two[3], two[4], two == "a\x00ba\x00b")
+	println(nul == "a", nul < "a\x00c", "a" < nul)
+
+	b := make([]byte, 3)
+	b[0] = 'x'
+	b[1] = 0
+	b[2] = 'y'
+	s := string(b)
+	t := s + "!"
+	println(len(s), len(t), t[3], t == "x\x00y!")
+
+	empty := ""
+	println(len(empty+empty), empty+"q", "p"+empty)
+}
+
+// expect: 3 97 0 98
+// expect: 6 97 0 true
+// expect: false true true
+// expect: 3 4 33 true
+// expect: 0 q p
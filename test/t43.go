@@ -0,0 +1,26 @@
+package main
+
+import "low"
+
+func main() {
+	// In the build directory, where the next build removes it.
+	name := "___.t43" + ".txt"
+	fd, errno := low.Creat(name, 420)
+	println(fd > 2, errno)
+	println(low.Close(fd))
+	fd, errno = low.Open(name, 0, 0)
+	println(fd > 2, errno)
+	println(low.Close(fd))
+
+	defer func() {
+		r := recover()
+		println(r.(string))
+	}()
+	fd, errno = low.Open("/tmp/no\x00such", 0, 0)
+}
+
+// expect: true 0
+// expect: 0
+// expect: true 0
+// expect: 0
+// expect: string with zero byte passed to OS
@@ -0,0 +1,46 @@
+package main
+
+func initial(s string) string {
+	return s[:1]
+}
+
+func main() {
+	s := "hello"
+	println(s[1:3], s[:2], s[2:], len(s[1:4]))
+	println(initial("world") == "w", s[1:3] < "em", s[:2]+s[3:])
+
+	b := []byte(s)
+	b[0] = 'j'
+	println(len(b), string(b), s)
+	println(string(b[1:3]))
+
+	println(string('A'), string(byte(66)))
+	e := string(233)
+	println(e, len(e))
+
+	var r rune
+	r = 'z'
+	println(string(r) + "!")
+	r = 0x7FFF
+	r = r + 0x2C01 // U+AC00, negative in a 16-bit int.
+	println(string(r), len(string(r)))
+
+	runes := []rune("héllo")
+	println(len(runes), runes[1], len("héllo"))
+	z := ""
+	for _, c := range runes {
+		z = z + string(c)
+	}
+	println(z)
+}
+
+// expect: el he llo 3
+// expect: true true helo
+// expect: 5 jello hello
+// expect: el
+// expect: A B
+// expect: é 2
+// expect: z!
+// expect: 가 3
+// expect: 5 233 6
+// expect: héllo
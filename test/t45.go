@@ -0,0 +1,48 @@
+package main
+
+func fill(n int) []byte {
+	b := make([]byte, n)
+	for i := 0; i < n; i++ {
+		b[i] = byte('a' + i%26)
+	}
+	return b
+}
+
+func main() {
+	sector := fill(1024)
+	println(len(sector), sector[0], sector[1023])
+	s := string(sector)
+	println(len(s), s[1000:1003])
+
+	z := ""
+	for i := 0; i < 100; i++ {
+		z = z + "0123456789"
+	}
+	println(len(z), z[995:])
+
+	var nums []int
+	for i := 0; i < 600; i++ {
+		nums = append(nums, i*2)
+	}
+	println(len(nums), nums[0], nums[300], nums[599])
+
+	var words []string
+	for i := 0; i < 300; i++ {
+		words = append(words, "w")
+	}
+	println(len(words), words[299])
+
+	defer func() {
+		r := recover()
+		println("recovered", r.(string))
+	}()
+	huge := make([]int, 20000)
+	println(len(huge))
+}
+
+// expect: 1024 97 106
+// expect: 1024 mno
+// expect: 1000 56789
+// expect: 600 0 600 1198
+// expect: 300 w
+// expect: recovered object too large for heap